@@ -0,0 +1,49 @@
+Feature: Concurrent requests
+
+  Scenario: Concurrent requests to create an order
+    When I request HTTP endpoint with method "POST" and URI "/order"
+
+    And I concurrently request idempotent HTTP endpoint "20" times
+
+    Then I should have response with status "Created"
+
+    And I should have other responses with status "Conflict"
+
+  Scenario: Concurrent requests with jitter
+    When I request HTTP endpoint with method "GET" and URI "/order"
+
+    And I concurrently request idempotent HTTP endpoint "5" times with jitter "50ms"
+
+    Then I should have response with status "OK"
+
+  Scenario: Not idempotent endpoint
+    When I request HTTP endpoint with method "GET" and URI "/flaky"
+
+    And I concurrently request idempotent HTTP endpoint "6" times
+
+    Then I should have response with status "OK"
+
+  Scenario: Unexpected body
+    When I request HTTP endpoint with method "GET" and URI "/order"
+
+    And I concurrently request idempotent HTTP endpoint "3" times
+
+    Then I should have response with body
+    """json
+    {"status":"created"}
+    """
+
+  Scenario: Too few concurrent requests
+    When I request HTTP endpoint with method "GET" and URI "/order"
+
+    And I concurrently request idempotent HTTP endpoint "1" times
+
+  Scenario: Long body in distribution
+    When I request HTTP endpoint with method "GET" and URI "/long"
+
+    And I concurrently request idempotent HTTP endpoint "2" times
+
+    Then I should have response with body
+    """json
+    {"status":"created"}
+    """
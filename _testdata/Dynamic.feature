@@ -40,4 +40,158 @@ Feature: Dynamic data is used in steps
      "created_at":"<ignore-diff>","updated_at": "<ignore-diff>",
      "user_id":"$user_id"
     }
-    """
\ No newline at end of file
+    """
+
+  Scenario: Expanding variables in request URI, header and cookie
+    When I request HTTP endpoint with method "POST" and URI "/user"
+
+    Then I should have response with JSON path "$.id" equal to "$user_id"
+
+    When I request HTTP endpoint with method "GET" and URI "/orders?user_id=$user_id"
+
+    And I request HTTP endpoint with header "X-User-Id: $user_id"
+
+    And I request HTTP endpoint with cookie "user: $user_id"
+
+    Then I should have response with status "OK"
+
+  Scenario: Checking response values with JSON path
+    When I request HTTP endpoint with method "POST" and URI "/user"
+
+    And I request HTTP endpoint with body
+    """json
+    {"name": "John Doe"}
+    """
+
+    # Undefined variable captures the value at JSON path.
+    Then I should have response with JSON path "$.id" equal to "$user_id"
+
+    And I should have response with JSON path "$.name" equal to "John Doe"
+
+    And I should have response with JSON paths
+      | $.id         | $user_id        |
+      | $.id         | 12345           |
+      | $.created_at | <ignore-diff>   |
+      | $            | {"name":"John Doe","id":12345,"created_at":"any","updated_at":"any"} |
+
+  Scenario: Checking response shape with JSON schema
+    When I request HTTP endpoint with method "POST" and URI "/user"
+
+    And I request HTTP endpoint with body
+    """json
+    {"name": "John Doe"}
+    """
+
+    Then I should have response matching JSON schema
+    """json5
+    {
+      "type": "object",
+      "required": ["id", "created_at"],
+      // Extra properties are allowed.
+      "properties": {"id": {"type": "integer"}, "created_at": {"type": "string"}}
+    }
+    """
+
+    And I should have response matching JSON schema from file
+    """
+    _testdata/user.schema.json
+    """
+
+  Scenario: Capturing values from response header and cookie
+    When I request HTTP endpoint with method "POST" and URI "/session"
+
+    Then I should have response with status "Created"
+
+    # Undefined variables capture values of header and cookie.
+    And I should have response with header "Location" captured as "$session_url"
+
+    And I should have response with cookie "session" captured as "$session_id"
+
+    When I request HTTP endpoint with method "GET" and URI "$session_url"
+
+    And I request HTTP endpoint with cookie "session: $session_id"
+
+    Then I should have response with status "OK"
+
+    # Defined variable is compared with the actual value.
+    And I should have response with header "X-Session" captured as "$session_id"
+
+    And I should have response with JSON path "$.user_id" equal to "$user_id"
+
+    # Number captured from JSON is compared with header value as a string.
+    And I should have response with header "X-User-Id" captured as "$user_id"
+
+  Scenario: Keeping literal values with variable prefix
+    When I request HTTP endpoint with method "POST" and URI "/echo"
+
+    And I request HTTP endpoint with header "X-Request-Id: rock & roll+"
+
+    Then I should have response with header "X-Request-Id" captured as "$query"
+
+    # Values are URL encoded in query, doubled prefix makes a literal value.
+    When I request HTTP endpoint with method "GET" and URI "/search?$$top=10&q=$query&literal=$$query"
+
+    And I request HTTP endpoint with header "X-Filter: $$filter eq $query"
+
+    Then I should have response with status "OK"
+
+  Scenario: Keeping literal generator calls
+    When I request HTTP endpoint with method "POST" and URI "/echo"
+
+    # Doubled prefix escapes generator call in request, call of unknown generator is kept as is.
+    And I request HTTP endpoint with body
+    """json
+    {"formula":"=$$sum(a,b)","note":"$$total(usd)","raw":"=$concat(a,b)"}
+    """
+
+    # Calls of unknown generators are also kept in expected response.
+    Then I should have response with body
+    """json
+    {"formula":"=$sum(a,b)","note":"<ignore-diff>","raw":"=$concat(a,b)"}
+    """
+
+    And I should have response with JSON path "$.note" equal to "$total(usd)"
+
+  Scenario: Generating unique values
+    When I request HTTP endpoint with method "POST" and URI "/echo"
+
+    # Generated value can be stored in a variable for later use.
+    And I request HTTP endpoint with header "X-Request-Id: $request_id=$uuid()"
+
+    And I request HTTP endpoint with body
+    """json5
+    {
+      "id": "$user_id=$uuid()",
+      // Generator can be a part of a string.
+      "email": "user-$randString(12)@example.com",
+      "age": "$age=$randInt(18,99)",
+      "created_at": "$now(RFC3339)",
+      "env": "foo"
+    }
+    """
+
+    Then I should have response with body
+    """json5
+    {
+      "id": "$user_id",
+      "email": "<ignore-diff>",
+      "age": "$age",
+      "created_at": "<ignore-diff>",
+      "env": "foo"
+    }
+    """
+
+    And I should have response with header "X-Request-Id" captured as "$request_id"
+
+    And I should have response matching JSON schema
+    """json5
+    {
+      "type": "object",
+      "properties": {
+        "id": {"type": "string", "pattern": "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$"},
+        "email": {"type": "string", "pattern": "^user-[a-zA-Z0-9]{12}@example.com$"},
+        "age": {"type": "integer", "minimum": 18, "maximum": 99},
+        "created_at": {"type": "string", "format": "date-time"}
+      }
+    }
+    """
@@ -0,0 +1,61 @@
+Feature: External Services capture variables from requests
+
+  Scenario: Order id generated by application is captured from outgoing request
+    # Undefined variable captures the value of received request on first encounter.
+    Given "order-service" receives "POST" request "/orders" with body
+    """json
+    {"order_id":"$order_id","item":"Watermelon"}
+    """
+
+    # Captured value is used in response.
+    And "order-service" responds with status "OK" and body
+    """json
+    {"order_id":"$order_id","status":"created"}
+    """
+
+    When I request HTTP endpoint with method "POST" and URI "/checkout"
+
+    Then I should have response with status "OK"
+
+    # Value captured by mock is used in following requests of application.
+    When I request HTTP endpoint with method "GET" and URI "/orders/$order_id"
+
+    Then I should have response with body
+    """json
+    {"order_id":"$order_id","status":"created"}
+    """
+
+  Scenario: Id generated by mock is returned to application
+    Given "order-service" receives "POST" request "/reservations"
+
+    # Generated value is stored in a variable to check it in following steps.
+    And "order-service" responds with status "OK" and body
+    """json
+    {"reservation_id":"$reservation_id=$uuid()","code":"$randInt(-9223372036854775808,9223372036854775807)"}
+    """
+
+    When I request HTTP endpoint with method "POST" and URI "/reserve"
+
+    Then I should have response with body
+    """json
+    {"reservation_id":"$reservation_id","code":"<ignore-diff>"}
+    """
+
+  Scenario: Expected request has generated values
+    # Generators are called in expected request and response bodies.
+    Given "order-service" receives "POST" request "/quotes" with body
+    """json
+    {"zone":"$env(HTTPDOG_TEST_REGION)-west"}
+    """
+
+    And "order-service" responds with status "OK" and body
+    """json
+    {"zone":"eu-west","price":10}
+    """
+
+    When I request HTTP endpoint with method "POST" and URI "/quote"
+
+    Then I should have response with body
+    """json
+    {"zone":"$env(HTTPDOG_TEST_REGION)-west","price":10}
+    """
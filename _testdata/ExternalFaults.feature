@@ -0,0 +1,60 @@
+Feature: External Services fail on network level
+
+  Scenario: Dropped connection
+    Given "flaky-service" receives "GET" request "/drop"
+    And "flaky-service" drops connection
+    And "flaky-service" responds with status "OK"
+
+    When I call "flaky-service" with "GET" "/drop"
+
+    Then I should have connection reset error
+
+  Scenario: Truncated body
+    Given "flaky-service" receives "GET" request "/truncate"
+    And "flaky-service" responds with truncated body after 10 bytes
+    And "flaky-service" responds with status "OK" and body
+    """json
+    {"message":"this body is truncated"}
+    """
+
+    When I call "flaky-service" with "GET" "/truncate"
+
+    Then I should have error "unexpected EOF"
+    And I should receive 10 bytes of response body
+
+  Scenario: Truncated body is shorter than number of bytes
+    Given "flaky-service" receives "GET" request "/truncate-short"
+    And "flaky-service" responds with truncated body after 100 bytes
+    And "flaky-service" responds with status "OK" and body
+    """json
+    {"message":"short"}
+    """
+
+    When I call "flaky-service" with "GET" "/truncate-short"
+
+    Then I should have error "unexpected EOF"
+    And I should receive 19 bytes of response body
+
+  Scenario: Invalid Content-Length
+    Given "flaky-service" receives "GET" request "/length"
+    And "flaky-service" responds with invalid Content-Length
+    And "flaky-service" responds with status "OK" and body
+    """json
+    {"message":"hello"}
+    """
+
+    When I call "flaky-service" with "GET" "/length"
+
+    Then I should have error "bad Content-Length"
+
+  Scenario: Malformed chunked body
+    Given "flaky-service" receives "GET" request "/chunked"
+    And "flaky-service" responds with malformed chunked body
+    And "flaky-service" responds with status "OK" and body
+    """json
+    {"message":"hello"}
+    """
+
+    When I call "flaky-service" with "GET" "/chunked"
+
+    Then I should have error "invalid byte in chunk length"
@@ -0,0 +1,56 @@
+Feature: External Services receive form data
+
+  Scenario: Multipart and URL encoded forms are matched by fields
+    Given "upload-service" receives "POST" request "/upload" with form data
+      | title  | Report                |
+      | tag    | red                   |
+      | tag    | green                 |
+      | author | @@john                |
+      | id     | $upload_id            |
+      | token  | <ignore-diff>         |
+      | report | @_testdata/sample.csv |
+
+    And "upload-service" responds with status "OK" and body
+    """json
+    {"id":"$upload_id"}
+    """
+
+    Given "upload-service" receives "POST" request "/publish" with form data
+      | id   | $upload_id |
+      | name | John Doe   |
+
+    And "upload-service" responds with status "OK"
+
+    # Expectation with plain body receives form data as is.
+    Given "upload-service" receives "POST" request "/legacy" with body
+    """
+    id=abc123&name=John+Doe
+    """
+
+    And "upload-service" responds with status "OK"
+
+    When I upload report to "upload-service"
+
+    Then I should receive response body
+    """json
+    {"id":"abc123"}
+    """
+
+  Scenario: Unexpected form field
+    Given "upload-service" receives "POST" request "/publish" with form data
+      | id   | def456   |
+      | name | Jane Doe |
+
+    And "upload-service" responds with status "OK"
+
+    When I publish report to "upload-service"
+
+    Then I should receive response body
+    """
+    unexpected request body: not equal:
+     {
+       "id": "def456",
+    -  "name": "Jane Doe"
+    +  "name": "John Doe"
+     }
+    """
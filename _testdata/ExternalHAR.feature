@@ -0,0 +1,24 @@
+Feature: External Services expectations are loaded from HAR file
+
+  Scenario: Mocked responses from HAR file
+    Given "user-service" receives requests from HAR file
+    """
+    _testdata/sample.har
+    """
+
+    When I call "user-service" with "GET" "/users/1"
+
+    # Body is decoded in HAR file, so Content-Encoding and Content-Length are not used, body is not a template.
+    Then I should receive response body
+    """json
+    {"name":"John","bio":"{{ .Body }}"}
+    """
+
+    And I should receive cookies "theme=dark, lang=en"
+
+    When I call "user-service" with "POST" "/users/1/avatar"
+
+    Then I should receive response body
+    """
+    avatar updated
+    """
@@ -0,0 +1,31 @@
+Feature: External Services respond slowly
+
+  Scenario: Delayed response
+    Given "slow-service" receives "GET" request "/slow"
+    And "slow-service" responds after "100ms"
+    And "slow-service" responds with status "OK" and body
+    """json
+    {"slow":true}
+    """
+
+    When I call "slow-service" with "GET" "/slow" and timeout "1s"
+
+    Then I should receive response after "100ms"
+
+  Scenario: Response is delayed beyond client timeout
+    Given "slow-service" receives "GET" request "/slow"
+    And "slow-service" responds after "1s"
+    And "slow-service" responds with status "OK"
+
+    When I call "slow-service" with "GET" "/slow" and timeout "100ms"
+
+    Then I should have timeout
+
+  Scenario: Hanging response
+    Given "slow-service" receives "GET" request "/hang"
+    And "slow-service" response hangs
+    And "slow-service" responds with status "OK"
+
+    When I call "slow-service" with "GET" "/hang" and timeout "100ms"
+
+    Then I should have timeout
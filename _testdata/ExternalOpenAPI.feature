@@ -0,0 +1,29 @@
+Feature: External Services with OpenAPI contract
+
+  Scenario: Expectation follows the contract
+    Given "user-service" receives "GET" request "/api/users/1"
+
+    And "user-service" request is received several times
+
+    And "user-service" responds with status "OK" and body
+    """json
+    {"id":1,"name":"John","email":null}
+    """
+
+  Scenario: Response body violates the contract
+    Given "user-service" receives "GET" request "/api/users/1"
+
+    And "user-service" responds with status "OK" and body
+    """json
+    {"id":"1"}
+    """
+
+  Scenario: Status is not declared
+    Given "user-service" receives "GET" request "/api/users/abc"
+
+    And "user-service" responds with status "I'm a teapot"
+
+  Scenario: Operation is not defined
+    Given "user-service" receives "GET" request "/api/orders"
+
+    And "user-service" responds with status "OK"
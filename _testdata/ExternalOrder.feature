@@ -0,0 +1,25 @@
+Feature: External Services are called in order
+
+  Scenario: Services are called in expected order
+    Given "auth-service" receives "POST" request "/token"
+    And "auth-service" responds with status "OK"
+
+    And "billing-service" receives "POST" request "/charge"
+    And "billing-service" responds with status "OK"
+
+    And external calls happen in order: auth-service, billing-service
+
+    When I call "auth-service" with "POST" "/token"
+    And I call "billing-service" with "POST" "/charge"
+
+  Scenario: Services are called in unexpected order
+    Given "auth-service" receives "POST" request "/token"
+    And "auth-service" responds with status "OK"
+
+    And "billing-service" receives "POST" request "/charge"
+    And "billing-service" responds with status "OK"
+
+    And external calls happen in order: auth-service, billing-service
+
+    When I call "billing-service" with "POST" "/charge"
+    And I call "auth-service" with "POST" "/token"
@@ -0,0 +1,119 @@
+Feature: External Services match request URI with patterns
+
+  Scenario: URI template captures variable
+    Given "order-service" receives "GET" request matching "/users/{$user_id}/orders/{id}"
+
+    And "order-service" responds with status "OK" and body
+    """json
+    {"user":"$user_id"}
+    """
+
+    When I call "order-service" with "GET" "/users/42/orders/7"
+
+    Then I should receive response body
+    """json
+    {"user":"42"}
+    """
+
+  Scenario: Regular expression captures variable
+    Given "order-service" receives "GET" request matching regexp "/users/(?P<user_id>\d+)/orders\?status=(open|closed)"
+
+    And "order-service" responds with status "OK" and body
+    """json
+    {"user":"$user_id"}
+    """
+
+    When I call "order-service" with "GET" "/users/43/orders?status=open"
+
+    Then I should receive response body
+    """json
+    {"user":"43"}
+    """
+
+  Scenario: Exact request URI is preferred over overlapping template
+    Given "order-service" receives "GET" request matching "/users/{$user_id}"
+    And "order-service" request is async
+    And "order-service" responds with status "OK" and body
+    """json
+    {"user":"$user_id"}
+    """
+
+    And "order-service" receives "GET" request "/users/me"
+    And "order-service" request is async
+    And "order-service" responds with status "OK" and body
+    """json
+    {"user":"me"}
+    """
+
+    When I call "order-service" with "GET" "/users/me"
+
+    Then I should receive response body
+    """json
+    {"user":"me"}
+    """
+
+    When I call "order-service" with "GET" "/users/44"
+
+    Then I should receive response body
+    """json
+    {"user":"44"}
+    """
+
+  Scenario: Variable is captured only by accepted expectation
+    Given "order-service" receives "POST" request matching "/users/{$user_id}"
+    And "order-service" request is async
+    And "order-service" responds with status "OK" and body
+    """json
+    {"user":"$user_id"}
+    """
+
+    And "order-service" receives "GET" request matching "/users/{id}"
+    And "order-service" request is async
+    And "order-service" responds with status "OK" and body
+    """json
+    {"found":true}
+    """
+
+    # Template of POST expectation matches, but request is accepted by GET expectation.
+    When I call "order-service" with "GET" "/users/5"
+
+    Then I should receive response body
+    """json
+    {"found":true}
+    """
+
+    When I call "order-service" with "POST" "/users/6"
+
+    Then I should receive response body
+    """json
+    {"user":"6"}
+    """
+
+  Scenario: Captured variable must have same value
+    Given "order-service" receives "GET" request matching "/users/{$user_id}"
+
+    And "order-service" responds with status "OK" and body
+    """json
+    {"user":"$user_id"}
+    """
+
+    And "order-service" receives "GET" request matching "/users/{$user_id}/orders"
+
+    And "order-service" responds with status "OK" and body
+    """json
+    []
+    """
+
+    When I call "order-service" with "GET" "/users/1"
+
+    Then I should receive response body
+    """json
+    {"user":"1"}
+    """
+
+    When I call "order-service" with "GET" "/users/2/orders"
+
+    Then I should receive response body
+    """
+    request uri "/users/{$user_id}/orders" expected, "/users/2/orders" received
+    """
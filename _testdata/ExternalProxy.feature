@@ -0,0 +1,38 @@
+Feature: External Services traffic is recorded and replayed
+
+  Scenario: Weather in cities
+    When I call "weather-service" with "GET" "/weather?city=Berlin"
+
+    Then I should receive response body
+    """json
+    {"city":"Berlin","temp":20}
+    """
+
+    When I call "weather-service" with "GET" "/weather?city=Paris"
+
+    Then I should receive response body
+    """json
+    {"city":"Paris","temp":20}
+    """
+
+    And I should receive cookies "region=eu, units=metric"
+
+  Scenario: ???
+    When I call "weather-service" with "GET" "/icon"
+
+    Then I should receive icon
+
+    And I should receive cookies "region=eu, units=metric"
+
+  Scenario Outline: Weather in a city
+    When I call "weather-service" with "GET" "/weather?city=<city>"
+
+    Then I should receive response body
+    """json
+    {"city":"<city>","temp":20}
+    """
+
+    Examples:
+      | city   |
+      | Madrid |
+      | Rome   |
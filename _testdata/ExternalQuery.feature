@@ -0,0 +1,48 @@
+Feature: External Services match query parameters in any order
+
+  Scenario: Query parameters in different order
+    Given "search-service" receives "GET" request "/search?q=foo&tag=a&tag=b"
+
+    And "search-service" responds with status "OK" and body
+    """json
+    {"found":true}
+    """
+
+    When I call "search-service" with "GET" "/search?tag=b&q=foo&tag=a"
+
+    Then I should receive response body
+    """json
+    {"found":true}
+    """
+
+  Scenario: Additional query parameters are allowed
+    Given "search-service" receives "GET" request "/search?q=foo"
+
+    And "search-service" request may have other query parameters
+
+    And "search-service" responds with status "OK" and body
+    """json
+    {"found":true}
+    """
+
+    When I call "search-service" with "GET" "/search?utm_source=mail&q=foo"
+
+    Then I should receive response body
+    """json
+    {"found":true}
+    """
+
+  Scenario: Additional query parameters are not allowed
+    Given "search-service" receives "GET" request "/search?q=foo"
+
+    And "search-service" responds with status "OK" and body
+    """json
+    {"found":true}
+    """
+
+    When I call "search-service" with "GET" "/search?utm_source=mail&q=foo"
+
+    Then I should receive response body
+    """
+    request uri "/search?q=foo" expected, "/search?utm_source=mail&q=foo" received
+    """
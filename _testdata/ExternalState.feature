@@ -0,0 +1,127 @@
+Feature: External Services with state
+
+  Scenario: Job is pending before it is done
+    Given "job-service" is in state "queued"
+
+    And "job-service" receives "GET" request "/jobs/1"
+    And "job-service" request is async
+    And "job-service" request is expected in state "queued"
+    And "job-service" response moves to state "running"
+    And "job-service" responds with status "OK" and body
+    """json
+    {"status":"pending"}
+    """
+
+    And "job-service" receives "GET" request "/jobs/1"
+    And "job-service" request is async
+    And "job-service" request is expected in state "running"
+    And "job-service" response moves to state "done"
+    And "job-service" responds with status "OK" and body
+    """json
+    {"status":"pending"}
+    """
+
+    And "job-service" receives "GET" request "/jobs/1"
+    And "job-service" request is async
+    And "job-service" request is expected in state "done"
+    And "job-service" responds with status "OK" and body
+    """json
+    {"status":"done"}
+    """
+
+    When I call "job-service" with "GET" "/jobs/1"
+    Then I should receive response body
+    """json
+    {"status":"pending"}
+    """
+
+    When I call "job-service" with "GET" "/jobs/1"
+    Then I should receive response body
+    """json
+    {"status":"pending"}
+    """
+
+    When I call "job-service" with "GET" "/jobs/1"
+    Then I should receive response body
+    """json
+    {"status":"done"}
+    """
+
+  Scenario: Resource exists after it is created
+    Given "job-service" receives "GET" request "/jobs/2"
+    And "job-service" request is async
+    And "job-service" request is expected in state ""
+    And "job-service" responds with status "Not Found" and body
+    """json
+    {"error":"not found"}
+    """
+
+    And "job-service" receives "POST" request "/jobs"
+    And "job-service" request is async
+    And "job-service" response moves to state "created"
+    And "job-service" responds with status "Created" and body
+    """json
+    {"id":2}
+    """
+
+    And "job-service" receives "GET" request "/jobs/2"
+    And "job-service" request is async
+    And "job-service" request is expected in state "created"
+    And "job-service" responds with status "OK" and body
+    """json
+    {"id":2,"status":"queued"}
+    """
+
+    When I call "job-service" with "GET" "/jobs/2"
+    Then I should receive response body
+    """json
+    {"error":"not found"}
+    """
+
+    When I call "job-service" with "POST" "/jobs"
+    Then I should receive response body
+    """json
+    {"id":2}
+    """
+
+    When I call "job-service" with "GET" "/jobs/2"
+    Then I should receive response body
+    """json
+    {"id":2,"status":"queued"}
+    """
+
+  Scenario: Sequential requests follow state
+    Given "job-service" receives "POST" request "/jobs/3/start"
+    And "job-service" request is expected in state ""
+    And "job-service" response moves to state "running"
+    And "job-service" responds with status "OK" and body
+    """json
+    {"status":"running"}
+    """
+
+    And "job-service" receives "GET" request "/jobs/3"
+    And "job-service" request is expected in state "running"
+    And "job-service" responds with status "OK" and body
+    """json
+    {"status":"running"}
+    """
+
+    When I call "job-service" with "POST" "/jobs/3/start"
+    Then I should receive response body
+    """json
+    {"status":"running"}
+    """
+
+    When I call "job-service" with "GET" "/jobs/3"
+    Then I should receive response body
+    """json
+    {"status":"running"}
+    """
+
+  Scenario: Request in unexpected state
+    Given "job-service" receives "GET" request "/jobs/4"
+    And "job-service" request is expected in state "done"
+    And "job-service" responds with status "OK"
+
+    When I call "job-service" with "GET" "/jobs/4"
+    Then I should receive error "unexpected state of service: \"done\" expected, \"\" received"
@@ -0,0 +1,81 @@
+Feature: External Services respond with templates
+
+  Scenario: Response refers to received request
+    Given "echo-service" receives "POST" request "/users?name=Alice"
+    And "echo-service" responds with status "OK" and body
+    """
+    {"name":{{ json .Query.name }},"nick":{{ json .JSON.user.nick }},"id":{{ .JSON.user.id }},"request_id":{{ json (.Header "X-Request-Id") }}}
+    """
+
+    When I call "echo-service" with "POST" "/users?name=Alice" and body
+    """json
+    {"user":{"id":123,"nick":"Al \"the pal\""}}
+    """
+
+    Then I should receive response body
+    """json
+    {"name":"Alice","nick":"Al \"the pal\"","id":123,"request_id":"req-1"}
+    """
+
+  Scenario: Response is rendered for each request
+    Given "echo-service" receives "POST" request "/echo"
+    And "echo-service" request is received several times
+    And "echo-service" responds with status "OK" and body from file
+    """
+    _testdata/echo.tmpl
+    """
+
+    When I call "echo-service" with "POST" "/echo" and body
+    """
+    foo
+    """
+
+    Then I should receive response body
+    """
+    POST /echo: foo
+    """
+
+    When I call "echo-service" with "POST" "/echo" and body
+    """
+    bar
+    """
+
+    Then I should receive response body
+    """
+    POST /echo: bar
+    """
+
+  Scenario: Literal braces are escaped in template
+    Given "echo-service" receives "POST" request "/literal"
+    And "echo-service" responds with status "OK" and body
+    """
+    Hello, {{ "{{" }} .Method }}!
+    """
+
+    When I call "echo-service" with "POST" "/literal" and body
+    """
+    foo
+    """
+
+    Then I should receive response body
+    """
+    Hello, {{ .Method }}!
+    """
+
+  Scenario: Missing value fails rendering
+    # Error is responded and reported after scenario.
+    Given "echo-service" receives "POST" request "/missing"
+    And "echo-service" responds with status "OK" and body
+    """
+    {"id":{{ .JSON.user.id }}}
+    """
+
+    When I call "echo-service" with "POST" "/missing" and body
+    """
+    foo
+    """
+
+    Then I should receive response body
+    """
+    failed to render response template: template: response:1:14: executing "response" at <.JSON.user.id>: nil pointer evaluating interface {}.user
+    """
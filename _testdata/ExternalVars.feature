@@ -0,0 +1,20 @@
+Feature: External Services with dynamic variables
+
+  Scenario: Variables are expanded in request URI and headers
+    Given variable "$user_id" is "12345"
+
+    And "user-service" receives "GET" request "/users/$user_id/orders"
+
+    And "user-service" request includes header "X-User-Id: $user_id"
+
+    And "user-service" responds with status "OK" and body
+    """json
+    {"user_id":"$user_id"}
+    """
+
+    When I call "user-service" with "GET" "/users/12345/orders" and header "X-User-Id: 12345"
+
+    Then I should receive response body
+    """json
+    {"user_id":"12345"}
+    """
@@ -0,0 +1,16 @@
+Feature: Compressed responses
+
+  Scenario: Compression negotiated by client
+    When I request HTTP endpoint with method "GET" and URI "/user"
+    Then I should have response with status "OK"
+    And I should have response with body
+    """
+    {"id":12345}
+    """
+    And I should have response with JSON path "$.id" equal to "12345"
+
+  Scenario: Compression requested explicitly
+    When I request HTTP endpoint with method "GET" and URI "/user"
+    And I request HTTP endpoint with header "Accept-Encoding: gzip"
+    Then I should have response with header "Content-Encoding: gzip"
+    And I should have response with JSON path "$.id" equal to "12345"
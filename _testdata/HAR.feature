@@ -0,0 +1,16 @@
+Feature: Exchanges are written to HAR file
+
+  Scenario: Profile is composed of user
+    Given "user-service" receives "GET" request "/users/1"
+    And "user-service" responds with status "OK" and body
+    """json
+    {"name":"John"}
+    """
+
+    When I request HTTP endpoint with method "GET" and URI "/profile?id=1"
+
+    Then I should have response with status "OK"
+    And I should have response with body
+    """json
+    {"profile":{"name":"John"}}
+    """
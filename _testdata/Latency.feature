@@ -0,0 +1,31 @@
+Feature: Response latency
+
+  Scenario: Fast response
+    When I request HTTP endpoint with method "GET" and URI "/fast"
+
+    Then I should have response with status "OK"
+
+    And I should have response within "1s"
+
+  Scenario: Fast concurrent responses
+    When I request HTTP endpoint with method "GET" and URI "/fast"
+
+    And I concurrently request idempotent HTTP endpoint
+
+    Then I should have response with status "OK"
+
+    And I should have response within "1s"
+
+    And I should have other responses p95 within "1s"
+
+  Scenario: Slow response
+    When I request HTTP endpoint with method "GET" and URI "/slow"
+
+    Then I should have response within "10ms"
+
+  Scenario: Slow concurrent responses
+    When I request HTTP endpoint with method "GET" and URI "/slow"
+
+    And I concurrently request idempotent HTTP endpoint
+
+    Then I should have other responses p90 within "10ms"
@@ -0,0 +1,66 @@
+Feature: HTTP Service
+
+  Scenario: Fail with unexpected JSON path values
+    When I request HTTP endpoint with method "GET" and URI "/user"
+
+    Then I should have response with JSON paths
+      | $.id   | 54321    |
+      | $.name | John Doe |
+      | $.foo  | bar      |
+
+  Scenario: Fail with JSON schema violations
+    When I request HTTP endpoint with method "GET" and URI "/user"
+
+    Then I should have response matching JSON schema
+    """json
+    {
+      "type": "object",
+      "required": ["email"],
+      "properties": {"id": {"type": "string"}, "name": {"maxLength": 3}}
+    }
+    """
+
+  Scenario: Fail with unknown variable in URI
+    When I request HTTP endpoint with method "GET" and URI "/user/$unknown"
+
+  Scenario: Fail with missing header and unexpected variable value
+    When I request HTTP endpoint with method "GET" and URI "/user"
+
+    Then I should have response with header "Content-Type" captured as "$type"
+
+    And I should have response with header "Content-Type" captured as "$type"
+
+    And I should have response with header "X-Missing" captured as "$missing"
+
+  Scenario: Fail with unexpected value of captured variable
+    When I request HTTP endpoint with method "GET" and URI "/user"
+
+    Then I should have response with JSON path "$.name" equal to "$name"
+
+    And I should have response with header "Content-Type" captured as "$name"
+
+  Scenario: Fail with missing environment variable
+    When I request HTTP endpoint with method "POST" and URI "/user"
+
+    And I request HTTP endpoint with body
+    """json
+    {"token": "$env(HTTPDOG_MISSING_ENV)"}
+    """
+
+  @reproducible
+  Scenario: Fail with reproducible request
+    Given I request HTTP endpoint with method "POST" and URI "/user?name=$name=$randString(4)"
+    And I request HTTP endpoint with header "X-Name: $name"
+    And I request HTTP endpoint with header "X-Note: it's me"
+    And I request HTTP endpoint with header "Authorization: Bearer secret"
+    And I request HTTP endpoint with cookie "session: secret"
+    And I request HTTP endpoint with body
+    """json
+    {"name":"$name"}
+    """
+
+    Then I should have response with status "Created"
+
+  Scenario: Fail with long response body
+    When I request HTTP endpoint with method "GET" and URI "/long"
+    Then I should have response with status "Created"
@@ -0,0 +1,26 @@
+Feature: Multiple local services
+
+  Scenario: Order is created in gateway and billed in billing service
+    When I request "gateway" HTTP endpoint with method "POST" and URI "/orders"
+
+    And I request "gateway" HTTP endpoint with header "X-Service: gateway"
+
+    And I request "gateway" HTTP endpoint with body
+    """json
+    {"item":"Watermelon"}
+    """
+
+    # Variables are shared between services.
+    Then I should have "gateway" response with body
+    """json
+    {"order_id":"$order_id","invoice_id":"$invoice_id"}
+    """
+
+    When I request "billing" HTTP endpoint with method "GET" and URI "/invoices/$invoice_id"
+
+    Then I should have "billing" response with status "OK"
+
+    And I should have "billing" response with JSON path "$.order_id" equal to "$order_id"
+
+    # Each service keeps own client state.
+    And I should have "gateway" response with status "Created"
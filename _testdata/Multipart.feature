@@ -0,0 +1,24 @@
+Feature: Multipart upload
+
+  Scenario: Uploading files with fields
+    When I request HTTP endpoint with method "POST" and URI "/upload"
+
+    And I request HTTP endpoint with multipart form data
+      | title   | Report $report_id      |                  |
+      | meta    | {"draft":true}         | application/json |
+      | author  | @@john                 |                  |
+      | editor  | $editor                |                  |
+      | report  | @_testdata/sample.csv  | text/csv         |
+      | details | @_testdata/sample.json |                  |
+
+    Then I should have response with body
+    """json
+    [
+      {"name": "title", "contentType": "", "data": "Report 42"},
+      {"name": "meta", "contentType": "application/json", "data": "{\"draft\":true}"},
+      {"name": "author", "contentType": "", "data": "@john"},
+      {"name": "editor", "contentType": "", "data": "@jane"},
+      {"name": "report", "fileName": "sample.csv", "contentType": "text/csv", "data": "a,b,c"},
+      {"name": "details", "fileName": "sample.json", "contentType": "application/json", "data": "{\n  \"error\": \"oops\"\n}"}
+    ]
+    """
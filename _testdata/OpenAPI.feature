@@ -0,0 +1,17 @@
+Feature: HTTP Service with OpenAPI contract
+
+  Scenario: Requests and responses follow the contract
+    When I request HTTP endpoint with method "GET" and URI "/users/1"
+
+    Then I should have response with status "OK"
+
+    When I request HTTP endpoint with method "POST" and URI "/users"
+
+    And I request HTTP endpoint with header "Content-Type: application/json"
+
+    And I request HTTP endpoint with body
+    """json
+    {"id":2,"name":"Jane"}
+    """
+
+    Then I should have response with status "Created"
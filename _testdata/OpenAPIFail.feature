@@ -0,0 +1,26 @@
+Feature: HTTP Service with OpenAPI contract
+
+  Scenario: Response body violates the contract
+    When I request HTTP endpoint with method "GET" and URI "/users/2"
+
+    Then I should have response with status "OK"
+
+  Scenario: Request violates the contract
+    When I request HTTP endpoint with method "GET" and URI "/users/abc"
+
+    Then I should have response with status "I'm a teapot"
+
+  Scenario: Query parameter violates the contract
+    When I request HTTP endpoint with method "GET" and URI "/users?limit=500"
+
+    Then I should have response with status "OK"
+
+  Scenario: Operation is not defined
+    When I request HTTP endpoint with method "DELETE" and URI "/users/1"
+
+    Then I should have response with status "No Content"
+
+  Scenario: Response and contract are both violated
+    When I request HTTP endpoint with method "GET" and URI "/users/2"
+
+    Then I should have response with status "Not Found"
@@ -0,0 +1,42 @@
+Feature: Polling
+
+  Scenario: Job is eventually done
+    When I request HTTP endpoint with method "GET" and URI "/job"
+
+    And I request HTTP endpoint with header "X-Job: abc"
+
+    # Request is repeated until all expectations are met.
+    And I request HTTP endpoint until response matches within "1s" every "10ms"
+
+    Then I should have response with status "OK"
+
+    And I should have response with body
+    """json
+    {"status":"done"}
+    """
+
+  Scenario: Job is never done
+    When I request HTTP endpoint with method "GET" and URI "/never"
+
+    And I request HTTP endpoint with header "X-Job: abc"
+
+    And I request HTTP endpoint until response matches within "200ms" every "50ms"
+
+    Then I should have response with body
+    """json
+    {"status":"done"}
+    """
+
+  Scenario: Variable captured by failed attempt is discarded
+    When I request HTTP endpoint with method "GET" and URI "/progress"
+
+    And I request HTTP endpoint with header "X-Job: abc"
+
+    And I request HTTP endpoint until response matches within "1s" every "10ms"
+
+    Then I should have response with body
+    """json
+    {"status":"done","updated_at":"$updated_at"}
+    """
+
+    And I should have response with JSON path "$.updated_at" equal to "3"
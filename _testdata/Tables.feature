@@ -0,0 +1,31 @@
+Feature: Request setup with tables
+
+  Scenario: Query parameters, form data and headers
+    Given variable "$query" is "café"
+
+    When I request HTTP endpoint with method "POST" and URI "/search?page=1"
+
+    And I request HTTP endpoint with query parameters
+      | tag   | red & blue |
+      | tag   | green      |
+      | query | $query     |
+
+    And I request HTTP endpoint with form data
+      | name    | John Doe     |
+      | comment | 100% = a+b?  |
+      | name    | Jane         |
+
+    And I request HTTP endpoint with headers
+      | X-Foo   | bar        |
+      | X-Query | $query     |
+
+    Then I should have response with body
+    """json
+    {
+      "uri": "/search?page=1&tag=red+%26+blue&tag=green&query=caf%C3%A9",
+      "body": "name=John+Doe&comment=100%25+%3D+a%2Bb%3F&name=Jane",
+      "contentType": "application/x-www-form-urlencoded",
+      "foo": "bar",
+      "query": "café"
+    }
+    """
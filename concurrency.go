@@ -0,0 +1,144 @@
+package httpdog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxSampleBody limits length of response body in distribution report.
+const maxSampleBody = 200
+
+// defaultConcurrencyLevel is a number of concurrent requests if Local.ConcurrencyLevel is not set.
+const defaultConcurrencyLevel = 10
+
+var (
+	errInvalidConcurrency     = errors.New("invalid number of concurrent requests")
+	errOperationNotIdempotent = errors.New("operation is not idempotent")
+	errSameStatus             = errors.New("all responses have same status, no other responses")
+)
+
+func (l *Local) iRequestWithConcurrency() error {
+	l.req.concurrency = l.ConcurrencyLevel
+	if l.req.concurrency == 0 {
+		l.req.concurrency = defaultConcurrencyLevel
+	}
+
+	return nil
+}
+
+func (l *Local) iRequestWithConcurrencyTimes(n int) error {
+	return l.iRequestWithConcurrencyTimesWithJitter(n, "0s")
+}
+
+// iRequestWithConcurrencyTimesWithJitter configures n concurrent requests.
+//
+// Single request can not be checked for idempotency, so number less than 2 is rejected.
+func (l *Local) iRequestWithConcurrencyTimesWithJitter(n int, jitter string) error {
+	if n < 2 {
+		return fmt.Errorf("%w: %d, at least 2 expected", errInvalidConcurrency, n)
+	}
+
+	j, err := time.ParseDuration(jitter)
+	if err != nil {
+		return fmt.Errorf("invalid jitter: %w", err)
+	}
+
+	l.req.concurrency = n
+	l.req.jitter = j
+
+	return nil
+}
+
+// split selects main and other responses of concurrent requests.
+//
+// Operation is considered idempotent if all responses have same status code (e.g. GET /resource: all 200 OK)
+// or all responses but one have same status code (e.g. POST /resource: one 200 OK, many 409 Conflict).
+// Main response is the one of a kind, statuses of other kind are not checked unless expected.
+func (l *Local) split(exchanges []exchange) error {
+	byStatus := make(map[int][]*exchange, 2)
+
+	for i := range exchanges {
+		code := exchanges[i].resp.StatusCode
+		byStatus[code] = append(byStatus[code], &exchanges[i])
+	}
+
+	var groups [][]*exchange
+
+	for _, g := range byStatus {
+		groups = append(groups, g)
+	}
+
+	// Single response goes first, lower status is main if both are single.
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i]) != len(groups[j]) {
+			return len(groups[i]) < len(groups[j])
+		}
+
+		return groups[i][0].resp.StatusCode < groups[j][0].resp.StatusCode
+	})
+
+	switch {
+	case len(groups) == 1:
+		l.resp = groups[0][0]
+	case len(groups) == 2 && len(groups[0]) == 1:
+		l.resp, l.other = groups[0][0], groups[1][0]
+	default:
+		return fmt.Errorf("%w: %d different statuses", errOperationNotIdempotent, len(groups))
+	}
+
+	return nil
+}
+
+// distribution describes statuses and bodies of all concurrent responses.
+func distribution(exchanges []exchange) string {
+	type group struct {
+		status int
+		body   string
+		count  int
+	}
+
+	var groups []*group
+
+	index := make(map[string]*group)
+
+	for _, ex := range exchanges {
+		body := truncated(strings.TrimSpace(string(ex.respBody)), maxSampleBody)
+
+		key := fmt.Sprintf("%d %s", ex.resp.StatusCode, body)
+
+		g, found := index[key]
+		if !found {
+			g = &group{status: ex.resp.StatusCode, body: body}
+			index[key] = g
+			groups = append(groups, g)
+		}
+
+		g.count++
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].count != groups[j].count {
+			return groups[i].count > groups[j].count
+		}
+
+		return groups[i].status < groups[j].status
+	})
+
+	res := fmt.Sprintf("distribution of %d responses:", len(exchanges))
+
+	for _, g := range groups {
+		res += fmt.Sprintf("\n%d with status %d (%s)", g.count, g.status, http.StatusText(g.status))
+
+		if g.body == "" {
+			res += ", no body"
+		} else {
+			res += ", body: " + g.body
+		}
+	}
+
+	return res
+}
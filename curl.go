@@ -0,0 +1,128 @@
+package httpdog
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxFailureBody limits length of response body in failure details.
+const maxFailureBody = 1000
+
+// maskedHeaders have credentials that are not shown in curl command if Local.MaskCredentials is enabled.
+var maskedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Cookie":              true,
+	"Proxy-Authorization": true,
+}
+
+// reproducible amends failure of response expectation with curl command of the request and received response.
+//
+// Error is not changed if there is no response.
+func (l *Local) reproducible(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if l.resp == nil {
+		return err
+	}
+
+	ex := *l.resp
+
+	return fmt.Errorf("%w\nrequest:\n%s\nresponse:\n%s", err, curlCommand(ex, l.MaskCredentials), rawResponse(ex))
+}
+
+// curlCommand makes a command to repeat the request in shell.
+//
+// Only headers configured for the request are included, defaults of transport (User-Agent, Accept-Encoding)
+// are left to curl. Values of credential headers are optionally masked, authorization scheme and cookie names are kept.
+func curlCommand(ex exchange, mask bool) string {
+	cmd := "curl -X " + ex.req.Method + " " + shellQuote(ex.req.URL.String())
+
+	for _, k := range sortedHeaderKeys(ex.req.Header) {
+		// Curl sets length of body itself.
+		if k == "Content-Length" {
+			continue
+		}
+
+		for _, v := range ex.req.Header[k] {
+			// Curl decodes compressed response with this option.
+			if k == "Accept-Encoding" && v == "gzip" {
+				cmd += " --compressed"
+
+				continue
+			}
+
+			if mask && maskedHeaders[k] {
+				v = maskHeader(k, v)
+			}
+
+			cmd += " -H " + shellQuote(k+": "+v)
+		}
+	}
+
+	if len(ex.reqBody) > 0 {
+		cmd += " --data-raw " + shellQuote(string(ex.reqBody))
+	}
+
+	return cmd
+}
+
+// rawResponse describes status, headers and truncated body of response.
+func rawResponse(ex exchange) string {
+	res := fmt.Sprintf("%s %s", ex.resp.Proto, ex.resp.Status)
+
+	for _, k := range sortedHeaderKeys(ex.resp.Header) {
+		for _, v := range ex.resp.Header[k] {
+			res += "\n" + k + ": " + v
+		}
+	}
+
+	body := truncated(string(ex.respBody), maxFailureBody)
+
+	if body != "" {
+		res += "\n\n" + body
+	}
+
+	return res
+}
+
+// truncated cuts long string on rune boundary and adds ellipsis, binary string is cut at most few bytes earlier.
+func truncated(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+
+	n := max
+	for n > max-utf8.UTFMax && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n] + "..."
+}
+
+// maskHeader replaces credentials in header value with ***.
+func maskHeader(k, v string) string {
+	if k != "Cookie" {
+		if i := strings.Index(v, " "); i > 0 {
+			return v[:i] + " ***"
+		}
+
+		return "***"
+	}
+
+	cookies := strings.Split(v, ";")
+
+	for i, c := range cookies {
+		name := strings.TrimSpace(strings.SplitN(c, "=", 2)[0])
+		cookies[i] = name + "=***"
+	}
+
+	return strings.Join(cookies, "; ")
+}
+
+// shellQuote quotes a string to be used as a single shell argument.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -13,6 +13,7 @@ import (
 func ExampleNewLocal() {
 	external := httpdog.External{}
 	templateService := external.Add("template-service")
+	defer external.Close()
 
 	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		req, _ := http.NewRequest(http.MethodGet, templateService+"/template/hello", nil)
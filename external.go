@@ -4,7 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/bool64/shared"
 	"github.com/cucumber/godog"
@@ -13,7 +18,15 @@ import (
 
 type exp struct {
 	resttest.Expectation
-	async bool
+	async        bool
+	partialQuery bool
+	uriKind      uriPattern
+	form         bool
+	behavior     *behavior
+
+	// Expectation is only used when service is in state.
+	conditional bool
+	state       string
 }
 
 // External is a collection of step-driven HTTP servers to serve requests of application with mocked data.
@@ -21,7 +34,31 @@ type External struct {
 	pending map[string]exp
 	mocks   map[string]*resttest.ServerMock
 
+	// serveMu serializes requests to mocks, so that they use current Vars and registered expectations.
+	serveMu  sync.Mutex
+	expected map[string][]*registered
+	servers  []*httptest.Server
+
+	// seq is a number of last registered expectation.
+	seq int
+
+	mu       sync.Mutex
+	calls    []call
+	orders   [][]string
+	failures []string
+
+	done    chan struct{}
+	states  map[string]string
+	proxies map[string]*Proxy
+
 	Vars *shared.Vars
+
+	// HAR is an optional writer of exchanges of mocked services.
+	HAR *HAR
+
+	// OpenAPI is an optional map of OpenAPI documents of real services by service name,
+	// expectations of a service are validated against its document when they are defined.
+	OpenAPI map[string]*OpenAPI
 }
 
 // RegisterSteps adds steps to godog scenario context to serve outgoing requests with mocked data.
@@ -50,10 +87,69 @@ type External struct {
 //		_testdata/sample.json
 //		"""
 //
+// Requests and responses can be loaded from a HAR file, entries commented with service name are used if there are any.
+//
+//		And "some-service" receives requests from HAR file
+//		"""
+//		_testdata/some-service.har
+//		"""
+//
+// Request with form data (URL encoded or multipart) is matched by parsed fields regardless of order.
+// Value prefixed with @ is a path to file, received file is compared by checksum of content,
+// @@ prefix escapes literal @ in value. Field can be ignored with <ignore-diff> or captured into a variable.
+//
+//		And "upload-service" receives "POST" request "/upload" with form data
+//		| title  | Report            |
+//		| tag    | red               |
+//		| tag    | green             |
+//		| author | @@john            |
+//		| token  | <ignore-diff>     |
+//		| report | @path/to/file.csv |
+//
+// Query parameters of request URI are matched in any order. Request can be allowed to have other query parameters.
+//
+//		And "some-service" request may have other query parameters
+//
+// Request URI can be matched with a template, placeholders match a path segment.
+// Placeholder named as a variable captures its value, defined variable must have same value.
+//
+//		Given "order-service" receives "GET" request matching "/users/{$user_id}/orders/{id}"
+//
+// Or with a regular expression for whole request URI, named groups capture variables.
+//
+//		Given "order-service" receives "GET" request matching regexp "/users/(?P<user_id>\d+)/orders\?.*"
+//
+// Variables are captured only by expectation that accepts the request.
+// Async expectations with exact request URI are preferred over patterns.
+//
 // Request can expect to have a header.
 //
 //		And "some-service" request includes header "X-Foo: bar"
 //
+// Variables are expanded in request URI and header values, unknown variable fails the step.
+// Doubled prefix makes a literal value, e.g. "$$top" is expected as "$top".
+//
+//		Given "some-service" receives "GET" request "/users/$user_id"
+//		And "some-service" request includes header "X-User-Id: $user_id"
+//
+// Undefined variables in request body capture values of received request on first encounter.
+// Captured values are used in response body and in following steps.
+//
+//		Given "order-service" receives "POST" request "/orders" with body
+//		"""
+//		{"order_id":"$order_id","item":"Watermelon"}
+//		"""
+//
+//		And "order-service" responds with status "OK" and body
+//		"""
+//		{"order_id":"$order_id","status":"created"}
+//		"""
+//
+// Vars can be shared with Local to use captured values in application requests and responses,
+// mocks use Vars that are set when request is received.
+//
+//		external := httpdog.External{Vars: local.JSONComparer.Vars}
+//
 // By default, each configured request is expected to be received 1 time. This can be changed to a different number.
 //
 //		And "some-service" request is received 1234 times
@@ -68,10 +164,46 @@ type External struct {
 //
 //		And "some-service" request is async
 //
+// Order of calls across services can be expected, services must have first calls in the order of the list.
+// Failure shows timeline of received calls.
+//
+//		And external calls happen in order: auth-service, billing-service
+//
+// Service can have a named state to respond differently on repeated calls, initial state is empty.
+// Expectation can be conditional on current state and response can move service to another state.
+// Conditional expectations are usually async, so that any of them can match.
+//
+//		Given "job-service" is in state "pending"
+//
+//		And "job-service" receives "GET" request "/jobs/1"
+//		And "job-service" request is async
+//		And "job-service" request is expected in state "pending"
+//		And "job-service" response moves to state "done"
+//		And "job-service" responds with status "OK" and body
+//		"""
+//		{"status":"pending"}
+//		"""
+//
 // Response may have a header.
 //
 //		And "some-service" response includes header "X-Bar: foo"
 //
+// Response can be delayed to test timeouts and retries.
+//
+//		And "some-service" responds after "2s"
+//
+// Or it can hang until client gives up, hanging response is aborted after scenario.
+//
+//		And "some-service" response hangs
+//
+// Network failures can be simulated, connection can be reset without response,
+// response body can be cut after a number of bytes, have invalid Content-Length or malformed chunked encoding.
+//
+//		And "some-service" drops connection
+//		And "some-service" responds with truncated body after 10 bytes
+//		And "some-service" responds with invalid Content-Length
+//		And "some-service" responds with malformed chunked body
+//
 // Response must have a status.
 //
 //		And "some-service" responds with status "OK"
@@ -83,6 +215,25 @@ type External struct {
 //		{"key":"value"}
 //		"""
 //
+// Response body with {{ }} actions is a template to render for each received request, also in file.
+// Template has request Method, Path, Query (first values), Body, decoded JSON body and Header function,
+// json function encodes a value as JSON. Literal braces can be rendered with {{ "{{" }}.
+//
+//		And "some-service" responds with status "OK" and body
+//		"""
+//		{"name":{{ json .Query.name }},"id":{{ .JSON.user.id }},"request_id":{{ json (.Header "X-Request-Id") }}}
+//		"""
+//
+// Missing value fails rendering, failure is reported after scenario.
+//
+// Generators produce values in expected request body and response body,
+// values are generated once when expectation is defined.
+//
+//		And "user-service" responds with status "OK" and body
+//		"""
+//		{"id":"$user_id=$uuid()"}
+//		"""
+//
 // Response body can also be defined in file.
 //
 //		And "another-service" responds with status "200" and body from file
@@ -99,16 +250,64 @@ func (e *External) RegisterSteps(s *godog.ScenarioContext) {
 			mock.ResetExpectations()
 		}
 
+		e.resetExpected()
+
+		e.mu.Lock()
+		e.calls = nil
+		e.orders = nil
+		e.failures = nil
+		e.states = nil
+		e.done = make(chan struct{})
+		e.mu.Unlock()
+
 		if e.Vars != nil {
 			e.Vars.Reset()
 		}
 
+		if e.HAR != nil {
+			e.HAR.start(sc)
+		}
+
+		for _, p := range e.proxies {
+			if p.Record {
+				continue
+			}
+
+			if err := e.replay(p, sc); err != nil {
+				return ctx, err
+			}
+		}
+
 		return ctx, nil
 	})
 
 	s.After(func(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
+		// Hanging responses are released.
+		e.mu.Lock()
+		if e.done != nil {
+			close(e.done)
+			e.done = nil
+		}
+		e.mu.Unlock()
+
 		var errs []string
 
+		if e.HAR != nil {
+			if err := e.HAR.finish(sc); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to write HAR file: %s", err))
+			}
+		}
+
+		for service, p := range e.proxies {
+			if !p.Record {
+				continue
+			}
+
+			if err := p.save(sc); err != nil {
+				errs = append(errs, fmt.Sprintf("failed to save recording of %s: %s", service, err))
+			}
+		}
+
 		if len(e.pending) > 0 {
 			for service, req := range e.pending {
 				errs = append(errs, fmt.Sprintf("%s in %s for %s %s",
@@ -122,6 +321,14 @@ func (e *External) RegisterSteps(s *godog.ScenarioContext) {
 			}
 		}
 
+		if err := e.checkOrders(); err != nil {
+			errs = append(errs, err.Error())
+		}
+
+		e.mu.Lock()
+		errs = append(errs, e.failures...)
+		e.mu.Unlock()
+
 		if len(errs) > 0 {
 			return ctx, errors.New("check failed for external services:\n" + strings.Join(errs, ",\n"))
 		}
@@ -138,20 +345,54 @@ func (e *External) steps(s *godog.ScenarioContext) {
 		e.serviceReceivesRequestWithBody)
 	s.Step(`^"([^"]*)" receives "([^"]*)" request "([^"]*)" with body from file$`,
 		e.serviceReceivesRequestWithBodyFromFile)
+	s.Step(`^"([^"]*)" receives "([^"]*)" request "([^"]*)" with form data$`,
+		e.serviceReceivesRequestWithFormData)
+	s.Step(`^"([^"]*)" receives "([^"]*)" request matching "([^"]*)"$`,
+		e.serviceReceivesRequestMatching)
+	s.Step(`^"([^"]*)" receives "([^"]*)" request matching regexp "([^"]*)"$`,
+		e.serviceReceivesRequestMatchingRegexp)
+	s.Step(`^"([^"]*)" receives requests from HAR file$`,
+		e.serviceReceivesRequestsFromHARFile)
 
 	// Configure request expectation.
 	s.Step(`^"([^"]*)" request includes header "([^"]*): ([^"]*)"$`,
 		e.serviceRequestIncludesHeader)
 	s.Step(`^"([^"]*)" request is async$`,
 		e.serviceRequestIsAsync)
+	s.Step(`^"([^"]*)" request may have other query parameters$`,
+		e.serviceRequestMayHaveOtherQueryParameters)
 	s.Step(`^"([^"]*)" request is received several times$`,
 		e.serviceReceivesRequestMultipleTimes)
 	s.Step(`^"([^"]*)" request is received (\d+) times$`,
 		e.serviceReceivesRequestNTimes)
+	s.Step(`^"([^"]*)" request is expected in state "([^"]*)"$`,
+		e.serviceRequestIsExpectedInState)
+
+	// Set state of a service.
+	s.Step(`^"([^"]*)" is in state "([^"]*)"$`,
+		e.serviceIsInState)
+
+	// Expect order of calls across services.
+	s.Step(`^external calls happen in order: (.+)$`,
+		e.externalCallsHappenInOrder)
 
 	// Configure response.
 	s.Step(`^"([^"]*)" response includes header "([^"]*): ([^"]*)"$`,
 		e.serviceResponseIncludesHeader)
+	s.Step(`^"([^"]*)" responds after "([^"]*)"$`,
+		e.serviceRespondsAfter)
+	s.Step(`^"([^"]*)" response moves to state "([^"]*)"$`,
+		e.serviceResponseMovesToState)
+	s.Step(`^"([^"]*)" response hangs$`,
+		e.serviceResponseHangs)
+	s.Step(`^"([^"]*)" drops connection$`,
+		e.serviceDropsConnection)
+	s.Step(`^"([^"]*)" responds with truncated body after (\d+) bytes$`,
+		e.serviceRespondsWithTruncatedBody)
+	s.Step(`^"([^"]*)" responds with invalid Content-Length$`,
+		e.serviceRespondsWithInvalidContentLength)
+	s.Step(`^"([^"]*)" responds with malformed chunked body$`,
+		e.serviceRespondsWithMalformedChunkedBody)
 
 	// Finalize request expectation.
 	s.Step(`^"([^"]*)" responds with status "([^"]*)"$`,
@@ -165,15 +406,25 @@ func (e *External) steps(s *godog.ScenarioContext) {
 }
 
 // GetMock exposes mock of external service.
+//
+// Mock is served by a server of External, own server of mock is closed.
 func (e *External) GetMock(service string) *resttest.ServerMock {
 	return e.mocks[service]
 }
 
 // Add starts a mocked server for a named service and returns url.
+//
+// Server prepares received requests for expectations of mock, servers are stopped with Close.
 func (e *External) Add(service string, options ...func(mock *resttest.ServerMock)) string {
-	mock, url := resttest.NewServerMock()
+	mock, _ := resttest.NewServerMock()
+
+	// Mock is served by a wrapper server that prepares requests.
+	mock.Close()
 
-	mock.JSONComparer.Vars = e.Vars
+	// Vars are needed to capture values from requests, mock uses current Vars of External unless options set Vars.
+	if e.Vars == nil {
+		e.Vars = &shared.Vars{}
+	}
 
 	for _, option := range options {
 		option(mock)
@@ -185,7 +436,86 @@ func (e *External) Add(service string, options ...func(mock *resttest.ServerMock
 
 	e.mocks[service] = mock
 
-	return url
+	srv := httptest.NewServer(e.handler(service, mock))
+	e.servers = append(e.servers, srv)
+
+	return srv.URL
+}
+
+// Close stops servers of mocked services, hanging responses are aborted.
+func (e *External) Close() {
+	e.mu.Lock()
+	if e.done != nil {
+		close(e.done)
+		e.done = nil
+	}
+	e.mu.Unlock()
+
+	for _, srv := range e.servers {
+		srv.Close()
+	}
+}
+
+// handler prepares request and passes it to mock.
+func (e *External) handler(service string, mock *resttest.ServerMock) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		e.recordCall(service, req.Method, req.RequestURI)
+
+		var (
+			started      = time.Now()
+			received     *http.Request
+			receivedBody []byte
+		)
+
+		// Request is kept before it is prepared for mock or forwarded to upstream.
+		if e.HAR != nil {
+			received, receivedBody = receivedRequest(req)
+		}
+
+		if p := e.recording(service); p != nil {
+			rec := httptest.NewRecorder()
+
+			p.forward(rec, req)
+			e.respond(rw, req, nil, rec)
+
+			if e.HAR != nil {
+				e.recordHAR(service, received, receivedBody, rec, started)
+			}
+
+			return
+		}
+
+		reqBody, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		// Response is recorded to be delivered without holding the mock.
+		var b *behavior
+
+		r, rec := e.serve(service, mock, req, reqBody)
+		if r != nil {
+			b = r.behavior
+		}
+
+		// Failure of template is reported with hooks of mock and fails scenario.
+		if b != nil && b.template != nil {
+			if err := renderBody(rec, b.template, req, reqBody); err != nil {
+				e.addFailure(fmt.Sprintf("%s in %s for %s %s", err, service, req.Method, req.RequestURI))
+
+				b, rec = nil, httptest.NewRecorder()
+				fail(mock, rec, err)
+			}
+		}
+
+		e.respond(rw, req, b, rec)
+
+		if e.HAR != nil {
+			e.recordHAR(service, received, receivedBody, rec, started)
+		}
+	})
 }
 
 func (e *External) serviceReceivesRequestWithPreparedBody(service, method, requestURI string, body []byte) error {
@@ -209,6 +539,11 @@ func (e *External) serviceRequestIncludesHeader(service, header, value string) e
 		return fmt.Errorf("%w: %q", errUndefinedRequest, service)
 	}
 
+	value, err := expandVars(value, e.Vars)
+	if err != nil {
+		return err
+	}
+
 	if pending.RequestHeader == nil {
 		pending.RequestHeader = make(map[string]string, 1)
 	}
@@ -220,7 +555,7 @@ func (e *External) serviceRequestIncludesHeader(service, header, value string) e
 }
 
 func (e *External) serviceReceivesRequestWithBody(service, method, requestURI string, bodyDoc *godog.DocString) error {
-	body, err := loadBody([]byte(bodyDoc.Content), e.Vars)
+	body, err := loadGeneratedBody([]byte(bodyDoc.Content), e.Vars)
 	if err != nil {
 		return err
 	}
@@ -229,7 +564,7 @@ func (e *External) serviceReceivesRequestWithBody(service, method, requestURI st
 }
 
 func (e *External) serviceReceivesRequestWithBodyFromFile(service, method, requestURI string, filePath *godog.DocString) error {
-	body, err := loadBodyFromFile(filePath.Content, e.Vars)
+	body, err := loadGeneratedBodyFromFile(filePath.Content, e.Vars)
 	if err != nil {
 		return err
 	}
@@ -242,6 +577,11 @@ func (e *External) serviceReceivesRequest(service, method, requestURI string) er
 		return fmt.Errorf("%w: %q", errNoMockForService, service)
 	}
 
+	requestURI, err := expandURI(requestURI, e.Vars)
+	if err != nil {
+		return err
+	}
+
 	pending := e.pending[service]
 	pending.Method = method
 	pending.RequestURI = requestURI
@@ -250,6 +590,35 @@ func (e *External) serviceReceivesRequest(service, method, requestURI string) er
 	return nil
 }
 
+func (e *External) serviceReceivesRequestMatching(service, method, template string) error {
+	return e.serviceReceivesRequestWithPattern(service, method, template, templateURI)
+}
+
+func (e *External) serviceReceivesRequestMatchingRegexp(service, method, expr string) error {
+	return e.serviceReceivesRequestWithPattern(service, method, expr, regexpURI)
+}
+
+// serviceReceivesRequestWithPattern expects request URI that matches a template or a regular expression.
+//
+// Variables are not expanded in pattern, placeholders and named groups capture them instead.
+func (e *External) serviceReceivesRequestWithPattern(service, method, pattern string, kind uriPattern) error {
+	if _, ok := e.mocks[service]; !ok {
+		return fmt.Errorf("%w: %q", errNoMockForService, service)
+	}
+
+	if _, err := newURIMatcher(pattern, kind, false, e.Vars); err != nil {
+		return err
+	}
+
+	pending := e.pending[service]
+	pending.Method = method
+	pending.RequestURI = pattern
+	pending.uriKind = kind
+	e.pending[service] = pending
+
+	return nil
+}
+
 func (e *External) serviceReceivesRequestNTimes(service string, n int) error {
 	if _, ok := e.mocks[service]; !ok {
 		return fmt.Errorf("%w: %q", errNoMockForService, service)
@@ -284,6 +653,23 @@ func (e *External) serviceRequestIsAsync(service string) error {
 	return nil
 }
 
+func (e *External) serviceRequestMayHaveOtherQueryParameters(service string) error {
+	if _, ok := e.mocks[service]; !ok {
+		return fmt.Errorf("%w: %q", errNoMockForService, service)
+	}
+
+	pending := e.pending[service]
+
+	if pending.Method == "" {
+		return fmt.Errorf("%w: %q", errUndefinedRequest, service)
+	}
+
+	pending.partialQuery = true
+	e.pending[service] = pending
+
+	return nil
+}
+
 func (e *External) serviceReceivesRequestMultipleTimes(service string) error {
 	if _, ok := e.mocks[service]; !ok {
 		return fmt.Errorf("%w: %q", errNoMockForService, service)
@@ -302,8 +688,7 @@ func (e *External) serviceReceivesRequestMultipleTimes(service string) error {
 }
 
 func (e *External) serviceRespondsWithStatusAndPreparedBody(service, statusOrCode string, body []byte) error {
-	m, ok := e.mocks[service]
-	if !ok {
+	if _, ok := e.mocks[service]; !ok {
 		return fmt.Errorf("%w: %q", errNoMockForService, service)
 	}
 
@@ -318,6 +703,26 @@ func (e *External) serviceRespondsWithStatusAndPreparedBody(service, statusOrCod
 		return fmt.Errorf("%w: %q", errUndefinedRequest, service)
 	}
 
+	// Regular expression can not be resolved to an operation.
+	if spec := e.OpenAPI[service]; spec != nil && pending.uriKind != regexpURI {
+		specBody := body
+
+		// Templated body depends on received request.
+		if pending.behavior != nil && pending.behavior.template != nil {
+			specBody = nil
+		}
+
+		err := spec.checkExpectation(pending.Method, pending.RequestURI, pending.uriKind, code, pending.ResponseHeader, specBody)
+		if err != nil {
+			return fmt.Errorf("invalid expectation for %s: %w", service, err)
+		}
+	}
+
+	uri, err := newURIMatcher(pending.RequestURI, pending.uriKind, pending.partialQuery, e.Vars)
+	if err != nil {
+		return fmt.Errorf("invalid request URI %q: %w", pending.RequestURI, err)
+	}
+
 	delete(e.pending, service)
 
 	pending.Status = code
@@ -327,11 +732,7 @@ func (e *External) serviceRespondsWithStatusAndPreparedBody(service, statusOrCod
 		pending.ResponseHeader = map[string]string{}
 	}
 
-	if pending.async {
-		m.ExpectAsync(pending.Expectation)
-	} else {
-		m.Expect(pending.Expectation)
-	}
+	e.expect(service, pending, uri)
 
 	return nil
 }
@@ -359,19 +760,19 @@ func (e *External) serviceResponseIncludesHeader(service, header, value string)
 }
 
 func (e *External) serviceRespondsWithStatusAndBody(service, statusOrCode string, bodyDoc *godog.DocString) error {
-	body, err := loadBody([]byte(bodyDoc.Content), e.Vars)
+	body, err := loadGeneratedBody([]byte(bodyDoc.Content), e.Vars)
 	if err != nil {
 		return err
 	}
 
-	return e.serviceRespondsWithStatusAndPreparedBody(service, statusOrCode, body)
+	return e.serviceRespondsWithStatusAndLoadedBody(service, statusOrCode, body)
 }
 
 func (e *External) serviceRespondsWithStatusAndBodyFromFile(service, statusOrCode string, filePath *godog.DocString) error {
-	body, err := loadBodyFromFile(filePath.Content, e.Vars)
+	body, err := loadGeneratedBodyFromFile(filePath.Content, e.Vars)
 	if err != nil {
 		return err
 	}
 
-	return e.serviceRespondsWithStatusAndPreparedBody(service, statusOrCode, body)
+	return e.serviceRespondsWithStatusAndLoadedBody(service, statusOrCode, body)
 }
@@ -2,12 +2,25 @@ package httpdog_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	"github.com/bool64/httpdog"
+	"github.com/bool64/shared"
 	"github.com/cucumber/godog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,6 +59,12 @@ func TestRegisterExternal(t *testing.T) {
 	assert.Contains(t, out.String(), "Error: after scenario hook failed: check failed for external services:\n"+
 		"undefined response (missing `responds with status <STATUS>` step) in some-service for GET /never-called,\n"+
 		"expectations were not met for another-service: there are remaining expectations that were not met: POST /post-something")
+
+	assert.NotPanics(t, es.GetMock("some-service").Close)
+	es.Close()
+
+	_, err := http.Get(someServiceURL + "/get-something?foo=bar") // nolint:bodyclose // Request fails.
+	assert.Error(t, err)
 }
 
 func callServices(t *testing.T, someServiceURL, anotherServiceURL string) func() error {
@@ -114,3 +133,871 @@ func callServices(t *testing.T, someServiceURL, anotherServiceURL string) func()
 		return nil
 	}
 }
+
+func TestExternal_RegisterSteps_openAPI(t *testing.T) {
+	spec, err := httpdog.LoadOpenAPIFile("_testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	es := httpdog.External{OpenAPI: map[string]*httpdog.OpenAPI{"user-service": spec}}
+	es.Add("user-service")
+
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalOpenAPI.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+
+	assert.Contains(t, out.String(), "4 scenarios (1 passed, 3 failed)")
+	assert.Contains(t, out.String(), "invalid expectation for user-service: OpenAPI contract violation for GET /api/users/1:\n"+
+		"response body /id: Field must be set to integer or not be present\n"+
+		"response body /name: property \"name\" is missing\n")
+	assert.Contains(t, out.String(), "invalid expectation for user-service: OpenAPI contract violation for GET /api/users/abc:\n"+
+		"path parameter \"id\": value abc: an invalid integer: strconv.ParseFloat: parsing \"abc\": invalid syntax\n"+
+		"response: status is not supported\n")
+	assert.Contains(t, out.String(), "invalid expectation for user-service: OpenAPI contract violation: "+
+		"operation is not defined in OpenAPI document: GET /api/orders\n")
+	assert.Contains(t, out.String(), "undefined response (missing `responds with status <STATUS>` step) "+
+		"in user-service for GET /api/orders")
+
+	// Mocked operations are not covered.
+	assert.Equal(t, []string{"GET /users", "GET /users/{id}", "POST /users"}, spec.Uncovered())
+}
+
+func TestExternal_RegisterSteps_vars(t *testing.T) {
+	es := httpdog.External{Vars: &shared.Vars{}}
+	serviceURL := es.Add("user-service")
+
+	var respBody []byte
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^variable "([^"]*)" is "([^"]*)"$`, func(name, value string) {
+				es.Vars.Set(name, value)
+			})
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)" and header "([^"]*): ([^"]*)"$`,
+				func(_, method, uri, key, value string) error {
+					req, err := http.NewRequest(method, serviceURL+uri, nil)
+					require.NoError(t, err)
+
+					req.Header.Set(key, value)
+
+					resp, err := http.DefaultTransport.RoundTrip(req)
+					require.NoError(t, err)
+
+					respBody, err = ioutil.ReadAll(resp.Body)
+					require.NoError(t, resp.Body.Close())
+
+					return err
+				})
+
+			s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+				return assertjson.FailNotEqual([]byte(body.Content), respBody)
+			})
+		},
+		Options: &godog.Options{
+			Format: "pretty",
+			Strict: true,
+			Paths:  []string{"_testdata/ExternalVars.feature"},
+		},
+	}
+
+	if suite.Run() != 0 {
+		t.Fatal("test failed")
+	}
+}
+
+func TestExternal_RegisterSteps_captureVars(t *testing.T) {
+	local := httpdog.NewLocal("")
+	es := httpdog.External{}
+	serviceURL := es.Add("order-service")
+
+	// Vars can be shared after services are added.
+	es.Vars = local.JSONComparer.Vars
+
+	var lastOrderID string
+
+	// Application generates order id and passes it to order service.
+	app := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Reservation is passed from order service as is.
+		if r.URL.Path == "/reserve" {
+			resp, err := http.Post(serviceURL+"/reservations", "", nil)
+			require.NoError(t, err)
+
+			defer resp.Body.Close() // nolint:errcheck // Body is read.
+
+			_, err = io.Copy(w, resp.Body)
+			assert.NoError(t, err)
+
+			return
+		}
+
+		// Quote request has zone of region from environment.
+		if r.URL.Path == "/quote" {
+			resp, err := http.Post(serviceURL+"/quotes", "application/json", strings.NewReader(`{"zone":"eu-west"}`))
+			require.NoError(t, err)
+
+			defer resp.Body.Close() // nolint:errcheck // Body is read.
+
+			_, err = io.Copy(w, resp.Body)
+			assert.NoError(t, err)
+
+			return
+		}
+
+		if r.URL.Path == "/orders/"+lastOrderID {
+			_, err := w.Write([]byte(`{"order_id":"` + lastOrderID + `","status":"created"}`))
+			assert.NoError(t, err)
+
+			return
+		}
+
+		orderID := strconv.FormatInt(time.Now().UnixNano(), 10)
+		lastOrderID = orderID
+
+		req, err := http.NewRequest(http.MethodPost, serviceURL+"/orders",
+			strings.NewReader(`{"order_id":"`+orderID+`","item":"Watermelon"}`))
+		require.NoError(t, err)
+
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, `{"order_id":"`+orderID+`","status":"created"}`, string(body))
+
+		_, err = w.Write([]byte(`{"order_id":"` + orderID + `"}`))
+		assert.NoError(t, err)
+	}))
+	defer app.Close()
+
+	local.SetBaseURL(app.URL)
+
+	require.NoError(t, os.Setenv("HTTPDOG_TEST_REGION", "eu"))
+
+	defer func() {
+		require.NoError(t, os.Unsetenv("HTTPDOG_TEST_REGION"))
+	}()
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+			es.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Format: "pretty",
+			Strict: true,
+			Paths:  []string{"_testdata/ExternalCapture.feature"},
+		},
+	}
+
+	if suite.Run() != 0 {
+		t.Fatal("test failed")
+	}
+}
+
+func TestExternal_RegisterSteps_formData(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("upload-service")
+	defer es.Close()
+	out := bytes.NewBuffer(nil)
+
+	var respBody []byte
+
+	send := func(uri, contentType string, body io.Reader) {
+		req, err := http.NewRequest(http.MethodPost, serviceURL+uri, body)
+		require.NoError(t, err)
+
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+
+		respBody, err = ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+	}
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I upload report to "([^"]*)"$`, func(string) {
+				buf := bytes.NewBuffer(nil)
+				w := multipart.NewWriter(buf)
+
+				// Fields order differs from expectation.
+				require.NoError(t, w.WriteField("tag", "red"))
+				require.NoError(t, w.WriteField("id", "abc123"))
+				require.NoError(t, w.WriteField("token", strconv.FormatInt(time.Now().UnixNano(), 10)))
+				require.NoError(t, w.WriteField("tag", "green"))
+				require.NoError(t, w.WriteField("author", "@john"))
+
+				f, err := w.CreateFormFile("report", "report.csv")
+				require.NoError(t, err)
+
+				_, err = f.Write([]byte("a,b,c"))
+				require.NoError(t, err)
+
+				require.NoError(t, w.WriteField("title", "Report"))
+				require.NoError(t, w.Close())
+
+				send("/upload", w.FormDataContentType(), buf)
+				uploadResp := respBody
+
+				send("/publish", "application/x-www-form-urlencoded",
+					strings.NewReader("name=John+Doe&id=abc123"))
+
+				send("/legacy", "application/x-www-form-urlencoded",
+					strings.NewReader("id=abc123&name=John+Doe"))
+
+				respBody = uploadResp
+			})
+
+			s.Step(`^I publish report to "([^"]*)"$`, func(string) {
+				send("/publish", "application/x-www-form-urlencoded",
+					strings.NewReader("name=John+Doe&id=def456"))
+			})
+
+			s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+				if json.Valid([]byte(body.Content)) {
+					return assertjson.FailNotEqual([]byte(body.Content), respBody)
+				}
+
+				if !strings.Contains(string(respBody), body.Content) {
+					return fmt.Errorf("unexpected response body: %s", respBody)
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalForm.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "2 scenarios (1 passed, 1 failed)")
+	assert.Contains(t, out.String(), "expectations were not met for upload-service: "+
+		"there are remaining expectations that were not met: POST /publish")
+}
+
+func TestExternal_RegisterSteps_query(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("search-service")
+	out := bytes.NewBuffer(nil)
+
+	var respBody []byte
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)"$`, func(_, method, uri string) error {
+				req, err := http.NewRequest(method, serviceURL+uri, nil)
+				require.NoError(t, err)
+
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				require.NoError(t, err)
+
+				respBody, err = ioutil.ReadAll(resp.Body)
+				require.NoError(t, resp.Body.Close())
+
+				return err
+			})
+
+			s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+				if json.Valid([]byte(body.Content)) {
+					return assertjson.FailNotEqual([]byte(body.Content), respBody)
+				}
+
+				if string(respBody) != body.Content {
+					return fmt.Errorf("unexpected response body: %s", respBody)
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalQuery.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "3 scenarios (2 passed, 1 failed)")
+	assert.Contains(t, out.String(), "expectations were not met for search-service: "+
+		"there are remaining expectations that were not met: GET /search?q=foo")
+}
+
+func TestExternal_RegisterSteps_pattern(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("order-service")
+	out := bytes.NewBuffer(nil)
+
+	var respBody []byte
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)"$`, func(_, method, uri string) error {
+				req, err := http.NewRequest(method, serviceURL+uri, nil)
+				require.NoError(t, err)
+
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				require.NoError(t, err)
+
+				respBody, err = ioutil.ReadAll(resp.Body)
+				require.NoError(t, resp.Body.Close())
+
+				return err
+			})
+
+			s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+				if json.Valid([]byte(body.Content)) {
+					return assertjson.FailNotEqual([]byte(body.Content), respBody)
+				}
+
+				if string(respBody) != body.Content {
+					return fmt.Errorf("unexpected response body: %s", respBody)
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalPattern.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "5 scenarios (4 passed, 1 failed)")
+	assert.Contains(t, out.String(), "expectations were not met for order-service: "+
+		"there are remaining expectations that were not met: GET /users/{$user_id}/orders")
+}
+
+func TestExternal_RegisterSteps_callsOrder(t *testing.T) {
+	es := httpdog.External{}
+	serviceURLs := map[string]string{
+		"auth-service":    es.Add("auth-service"),
+		"billing-service": es.Add("billing-service"),
+	}
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)"$`, func(service, method, uri string) error {
+				req, err := http.NewRequest(method, serviceURLs[service]+uri, nil)
+				require.NoError(t, err)
+
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				require.NoError(t, err)
+
+				return resp.Body.Close()
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalOrder.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "2 scenarios (1 passed, 1 failed)")
+	assert.Contains(t, out.String(), "unexpected order of external calls: billing-service was called before auth-service")
+	assert.Regexp(t, `call timeline:\n\s*1\. \+0s billing-service POST /charge\n\s*2\. \+[\d.]+[µm]?s auth-service POST /token`,
+		out.String())
+}
+
+func TestExternal_RegisterSteps_latency(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("slow-service")
+	out := bytes.NewBuffer(nil)
+
+	var (
+		elapsed time.Duration
+		callErr error
+	)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)" and timeout "([^"]*)"$`,
+				func(_, method, uri, timeout string) error {
+					d, err := time.ParseDuration(timeout)
+					require.NoError(t, err)
+
+					req, err := http.NewRequest(method, serviceURL+uri, nil)
+					require.NoError(t, err)
+
+					start := time.Now()
+					resp, err := (&http.Client{Timeout: d}).Do(req)
+					elapsed = time.Since(start)
+					callErr = err
+
+					if err == nil {
+						require.NoError(t, resp.Body.Close())
+					}
+
+					return nil
+				})
+
+			s.Step(`^I should receive response after "([^"]*)"$`, func(delay string) error {
+				d, err := time.ParseDuration(delay)
+				require.NoError(t, err)
+
+				if callErr != nil {
+					return callErr
+				}
+
+				if elapsed < d {
+					return fmt.Errorf("response received too early: %s", elapsed)
+				}
+
+				return nil
+			})
+
+			s.Step(`^I should have timeout$`, func() error {
+				if callErr == nil {
+					return errors.New("timeout expected")
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalLatency.feature"},
+		},
+	}
+
+	assert.Equal(t, 0, suite.Run(), out.String())
+}
+
+func TestExternal_RegisterSteps_faults(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("flaky-service")
+	out := bytes.NewBuffer(nil)
+
+	var (
+		respBody []byte
+		callErr  error
+	)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)"$`, func(_, method, uri string) error {
+				req, err := http.NewRequest(method, serviceURL+uri, nil)
+				require.NoError(t, err)
+
+				respBody = nil
+
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				if err != nil {
+					callErr = err
+
+					return nil
+				}
+
+				respBody, callErr = ioutil.ReadAll(resp.Body)
+				require.NoError(t, resp.Body.Close())
+
+				return nil
+			})
+
+			s.Step(`^I should have error "([^"]*)"$`, func(msg string) error {
+				if callErr == nil || !strings.Contains(callErr.Error(), msg) {
+					return fmt.Errorf("error %q expected, %v received", msg, callErr)
+				}
+
+				return nil
+			})
+
+			s.Step(`^I should have connection reset error$`, func() error {
+				if !errors.Is(callErr, syscall.ECONNRESET) {
+					return fmt.Errorf("connection reset expected, %v received", callErr)
+				}
+
+				return nil
+			})
+
+			s.Step(`^I should receive (\d+) bytes of response body$`, func(n int) error {
+				if len(respBody) != n {
+					return fmt.Errorf("unexpected response body: %s", respBody)
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalFaults.feature"},
+		},
+	}
+
+	assert.Equal(t, 0, suite.Run(), out.String())
+}
+
+func TestExternal_RegisterSteps_state(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("job-service")
+	out := bytes.NewBuffer(nil)
+
+	var respBody []byte
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)"$`, func(_, method, uri string) error {
+				req, err := http.NewRequest(method, serviceURL+uri, nil)
+				require.NoError(t, err)
+
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				require.NoError(t, err)
+
+				respBody, err = ioutil.ReadAll(resp.Body)
+				require.NoError(t, resp.Body.Close())
+
+				return err
+			})
+
+			s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+				return assertjson.FailNotEqual([]byte(body.Content), respBody)
+			})
+
+			s.Step(`^I should receive error "(.+)"$`, func(msg string) error {
+				if !strings.Contains(string(respBody), strings.ReplaceAll(msg, `\"`, `"`)) {
+					return fmt.Errorf("unexpected response body: %s", respBody)
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalState.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run(), out.String())
+	assert.Contains(t, out.String(), "4 scenarios (3 passed, 1 failed)")
+	assert.NotContains(t, out.String(), "unexpected response body")
+	assert.Contains(t, out.String(), "expectations were not met for job-service: "+
+		"there are remaining expectations that were not met: GET /jobs/4")
+}
+
+func TestExternal_RegisterSteps_template(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("echo-service")
+	out := bytes.NewBuffer(nil)
+
+	var respBody []byte
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)" and body$`,
+				func(_, method, uri string, body *godog.DocString) error {
+					req, err := http.NewRequest(method, serviceURL+uri, strings.NewReader(body.Content))
+					require.NoError(t, err)
+
+					req.Header.Set("X-Request-Id", "req-1")
+
+					resp, err := http.DefaultTransport.RoundTrip(req)
+					require.NoError(t, err)
+
+					respBody, err = ioutil.ReadAll(resp.Body)
+					require.NoError(t, resp.Body.Close())
+
+					return err
+				})
+
+			s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+				if json.Valid([]byte(body.Content)) {
+					return assertjson.FailNotEqual([]byte(body.Content), respBody)
+				}
+
+				if string(respBody) != body.Content {
+					return fmt.Errorf("unexpected response body: %s", respBody)
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalTemplate.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run(), out.String())
+	assert.Contains(t, out.String(), "4 scenarios (3 passed, 1 failed)")
+	assert.Contains(t, out.String(), "after scenario hook failed: check failed for external services:\n"+
+		"failed to render response template: template: response:1:14: executing \"response\" at <.JSON.user.id>: "+
+		"nil pointer evaluating interface {}.user in echo-service for POST /missing")
+}
+
+func TestExternal_AddProxy(t *testing.T) {
+	icon := []byte{0x89, 'P', 'N', 'G', 0xff, 0x00, 0xfe}
+
+	// Upstream compresses responses when client accepts gzip.
+	upstream := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		body := []byte(`{"city":"` + req.URL.Query().Get("city") + `","temp":20}`)
+		rw.Header().Set("Content-Type", "application/json")
+
+		if req.URL.Path == "/icon" {
+			body = icon
+			rw.Header().Set("Content-Type", "image/png")
+		}
+
+		rw.Header().Add("Set-Cookie", "region=eu")
+		rw.Header().Add("Set-Cookie", "units=metric")
+
+		if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+			_, _ = rw.Write(body)
+
+			return
+		}
+
+		rw.Header().Set("Content-Encoding", "gzip")
+
+		zw := gzip.NewWriter(rw)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+	}))
+
+	dir, err := ioutil.TempDir("", "recordings")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	run := func(record bool) (int, string) {
+		es := httpdog.External{HAR: &httpdog.HAR{Dir: filepath.Join(dir, "har")}}
+		serviceURL := es.AddProxy("weather-service", upstream.URL, func(p *httpdog.Proxy) {
+			p.Record = record
+			p.Dir = dir
+		})
+		out := bytes.NewBuffer(nil)
+
+		var (
+			respBody   []byte
+			respHeader http.Header
+		)
+
+		suite := godog.TestSuite{
+			ScenarioInitializer: func(s *godog.ScenarioContext) {
+				es.RegisterSteps(s)
+
+				s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)"$`, func(_, method, uri string) error {
+					req, err := http.NewRequest(method, serviceURL+uri, nil)
+					require.NoError(t, err)
+
+					// Client accepts compression, but does not decode response as it sets header itself.
+					req.Header.Set("Accept-Encoding", "gzip")
+
+					resp, err := http.DefaultTransport.RoundTrip(req)
+					require.NoError(t, err)
+
+					respHeader = resp.Header
+					respBody, err = ioutil.ReadAll(resp.Body)
+					require.NoError(t, resp.Body.Close())
+
+					return err
+				})
+
+				s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+					return assertjson.FailNotEqual([]byte(body.Content), respBody)
+				})
+
+				s.Step(`^I should receive icon$`, func() error {
+					if !bytes.Equal(icon, respBody) {
+						return fmt.Errorf("unexpected response body: %q", respBody)
+					}
+
+					return nil
+				})
+
+				s.Step(`^I should receive cookies "([^"]*)"$`, func(cookies string) error {
+					if received := strings.Join(respHeader["Set-Cookie"], ", "); received != cookies {
+						return fmt.Errorf("unexpected cookies: %s", received)
+					}
+
+					return nil
+				})
+			},
+			Options: &godog.Options{
+				Format:   "pretty",
+				Output:   out,
+				NoColors: true,
+				Strict:   true,
+				Paths:    []string{"_testdata/ExternalProxy.feature"},
+			},
+		}
+
+		return suite.Run(), out.String()
+	}
+
+	status, out := run(true)
+	assert.Equal(t, 0, status, out)
+
+	recording, err := ioutil.ReadFile(filepath.Join(dir, "weather-service", "externalproxy", "weather-in-cities.json"))
+	require.NoError(t, err)
+	assert.Contains(t, string(recording), `"requestUri": "/weather?city=Paris"`)
+	assert.Contains(t, string(recording), `"responseBody": "{\"city\":\"Paris\",\"temp\":20}"`)
+	assert.NotContains(t, string(recording), "Content-Encoding")
+
+	// Forwarded traffic is also written to HAR file.
+	har, err := ioutil.ReadFile(filepath.Join(dir, "har", "externalproxy", "weather-in-cities.har"))
+	require.NoError(t, err)
+	assert.Contains(t, string(har), `"comment": "weather-service"`)
+	assert.Contains(t, string(har), `/weather?city=Paris`)
+	assert.Contains(t, string(har), `{\"city\":\"Paris\",\"temp\":20}`)
+
+	// Scenario name without letters is stored with a hash.
+	files, err := filepath.Glob(filepath.Join(dir, "weather-service", "externalproxy", "scenario-*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	recording, err = ioutil.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(recording), `"responseBodyEncoding": "base64"`)
+
+	// Examples of scenario outline are stored separately with hashes of their values.
+	files, err = filepath.Glob(filepath.Join(dir, "weather-service", "externalproxy", "weather-in-a-city-*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+
+	var cities []string
+
+	for _, f := range files {
+		assert.Regexp(t, `weather-in-a-city-[0-9a-f]{8}\.json$`, f)
+
+		recording, err = ioutil.ReadFile(f)
+		require.NoError(t, err)
+
+		for _, city := range []string{"Madrid", "Rome"} {
+			if strings.Contains(string(recording), `"requestUri": "/weather?city=`+city+`"`) {
+				cities = append(cities, city)
+			}
+		}
+	}
+
+	assert.ElementsMatch(t, []string{"Madrid", "Rome"}, cities)
+
+	// Recorded traffic is replayed without upstream.
+	upstream.Close()
+
+	status, out = run(false)
+	assert.Equal(t, 0, status, out)
+}
+
+func TestExternal_RegisterSteps_harFile(t *testing.T) {
+	es := httpdog.External{}
+	serviceURL := es.Add("user-service")
+	out := bytes.NewBuffer(nil)
+
+	var (
+		respBody   []byte
+		respHeader http.Header
+	)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			es.RegisterSteps(s)
+
+			s.Step(`^I call "([^"]*)" with "([^"]*)" "([^"]*)"$`, func(_, method, uri string) error {
+				req, err := http.NewRequest(method, serviceURL+uri, nil)
+				require.NoError(t, err)
+
+				resp, err := http.DefaultTransport.RoundTrip(req)
+				require.NoError(t, err)
+
+				respHeader = resp.Header
+				respBody, err = ioutil.ReadAll(resp.Body)
+				require.NoError(t, resp.Body.Close())
+
+				return err
+			})
+
+			s.Step(`^I should receive response body$`, func(body *godog.DocString) error {
+				if json.Valid([]byte(body.Content)) {
+					return assertjson.FailNotEqual([]byte(body.Content), respBody)
+				}
+
+				if string(respBody) != body.Content {
+					return fmt.Errorf("unexpected response body: %s", respBody)
+				}
+
+				return nil
+			})
+
+			s.Step(`^I should receive cookies "([^"]*)"$`, func(cookies string) error {
+				if received := strings.Join(respHeader["Set-Cookie"], ", "); received != cookies {
+					return fmt.Errorf("unexpected cookies: %s", received)
+				}
+
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:   "pretty",
+			Output:   out,
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/ExternalHAR.feature"},
+		},
+	}
+
+	assert.Equal(t, 0, suite.Run(), out.String())
+}
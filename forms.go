@@ -0,0 +1,160 @@
+package httpdog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+
+	"github.com/cucumber/godog"
+)
+
+// checksumPrefix marks checksum of file content that represents file in form data.
+const checksumPrefix = "sha256:"
+
+// serviceReceivesRequestWithFormData expects form data as JSON object to compare it with received form.
+//
+// Repeated fields have array values, files are represented with checksums of content.
+func (e *External) serviceReceivesRequestWithFormData(service, method, requestURI string, table *godog.Table) error {
+	m, ok := e.mocks[service]
+	if !ok {
+		return fmt.Errorf("%w: %q", errNoMockForService, service)
+	}
+
+	fields := make(map[string][]string, len(table.Rows))
+
+	for _, row := range table.Rows {
+		if len(row.Cells) != 2 {
+			return fmt.Errorf("%w: 2 cells expected, %d received", errInvalidTable, len(row.Cells))
+		}
+
+		name := row.Cells[0].Value
+		value, isFile := filePath(row.Cells[1].Value)
+
+		switch {
+		case isFile:
+			data, err := ioutil.ReadFile(value) // nolint:gosec // File inclusion via variable during tests.
+			if err != nil {
+				return err
+			}
+
+			value = checksum(data)
+		case value == m.JSONComparer.IgnoreDiff || e.Vars.IsVar(value):
+			// Comparer ignores or captures these values.
+		default:
+			v, err := expandVars(value, e.Vars)
+			if err != nil {
+				return err
+			}
+
+			value = v
+		}
+
+		fields[name] = append(fields[name], value)
+	}
+
+	body, err := json.Marshal(formJSON(fields))
+	if err != nil {
+		return err
+	}
+
+	if err := e.serviceReceivesRequestWithPreparedBody(service, method, requestURI, body); err != nil {
+		return err
+	}
+
+	pending := e.pending[service]
+	pending.form = true
+	e.pending[service] = pending
+
+	return nil
+}
+
+// formToJSON converts form data body of request to JSON representation.
+//
+// Body is returned as is if it can not be parsed.
+func formToJSON(contentType string, body []byte) []byte {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return body
+	}
+
+	if mediaType != "application/x-www-form-urlencoded" && mediaType != "multipart/form-data" {
+		return body
+	}
+
+	var fields map[string][]string
+
+	if mediaType == "multipart/form-data" {
+		fields, err = multipartFields(body, params["boundary"])
+	} else {
+		fields, err = url.ParseQuery(string(body))
+	}
+
+	if err != nil {
+		return body
+	}
+
+	j, err := json.Marshal(formJSON(fields))
+	if err != nil {
+		return body
+	}
+
+	return j
+}
+
+// multipartFields reads values of multipart fields, file values are replaced with checksums.
+func multipartFields(body []byte, boundary string) (map[string][]string, error) {
+	r := multipart.NewReader(bytes.NewReader(body), boundary)
+	fields := make(map[string][]string)
+
+	for {
+		p, err := r.NextPart()
+		if errors.Is(err, io.EOF) {
+			return fields, nil
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(p)
+		if err != nil {
+			return nil, err
+		}
+
+		value := string(data)
+		if p.FileName() != "" {
+			value = checksum(data)
+		}
+
+		fields[p.FormName()] = append(fields[p.FormName()], value)
+	}
+}
+
+// formJSON makes JSON value of form fields, single values are strings and repeated values are arrays.
+func formJSON(fields map[string][]string) map[string]interface{} {
+	res := make(map[string]interface{}, len(fields))
+
+	for name, values := range fields {
+		if len(values) == 1 {
+			res[name] = values[0]
+		} else {
+			res[name] = values
+		}
+	}
+
+	return res
+}
+
+func checksum(data []byte) string {
+	h := sha256.Sum256(data)
+
+	return checksumPrefix + hex.EncodeToString(h[:])
+}
@@ -0,0 +1,410 @@
+package httpdog
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bool64/shared"
+	"github.com/swaggest/assertjson/json5"
+)
+
+var (
+	errInvalidArguments = errors.New("invalid generator arguments")
+	errMissingEnv       = errors.New("environment variable is not set")
+)
+
+// generators produce dynamic values by name.
+var generators = map[string]func(args []string) (interface{}, error){
+	"uuid":       genUUID,
+	"now":        genNow,
+	"randInt":    genRandInt,
+	"randString": genRandString,
+	"env":        genEnv,
+}
+
+var timeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+}
+
+// generatorExpr matches generator call with optional assignment to a variable,
+// for example $uuid() or $user_id=$uuid(), doubled prefix escapes the call.
+const generatorExpr = `(%[1]s)?(?:%[1]s([A-Za-z_][A-Za-z0-9_]*)=)?%[1]s([A-Za-z_][A-Za-z0-9_]*)\(([^()]*)\)`
+
+var (
+	// generatorPattern matches generator calls with default prefix.
+	generatorPattern = regexp.MustCompile(fmt.Sprintf(generatorExpr, regexp.QuoteMeta("$")))
+
+	// quotedGeneratorPattern matches generator calls that take a whole JSON string.
+	quotedGeneratorPattern = regexp.MustCompile(`"` + generatorPattern.String() + `"`)
+)
+
+// generatorCall is a matched generator call.
+type generatorCall struct {
+	call    string
+	escaped bool
+	varName string
+	name    string
+	args    string
+}
+
+// replaceCalls replaces generator calls matched by expression with results of a function.
+//
+// Calls of unknown generators are not passed to function unless escaped.
+func replaceCalls(s string, re *regexp.Regexp, f func(c generatorCall) (string, error)) (string, error) {
+	var (
+		res = strings.Builder{}
+		pos int
+	)
+
+	for _, m := range re.FindAllStringSubmatchIndex(s, -1) {
+		c := generatorCall{call: s[m[0]:m[1]], escaped: m[2] >= 0, name: s[m[6]:m[7]], args: s[m[8]:m[9]]}
+
+		if m[4] >= 0 {
+			c.varName = s[m[4]:m[5]]
+		}
+
+		// Calls of unknown generators are kept as is.
+		if _, found := generators[c.name]; !found && !c.escaped {
+			continue
+		}
+
+		r, err := f(c)
+		if err != nil {
+			return "", err
+		}
+
+		res.WriteString(s[pos:m[0]])
+		res.WriteString(r)
+
+		pos = m[1]
+	}
+
+	res.WriteString(s[pos:])
+
+	return res.String(), nil
+}
+
+// unescaped returns escaped call with a single prefix.
+func (c generatorCall) unescaped(vars *shared.Vars) string {
+	return c.call[len(varPrefix(vars)):]
+}
+
+// generate replaces generator calls in a string with generated values, optional escape is applied to values.
+func generate(s string, vars *shared.Vars, escape func(string) string) (string, error) {
+	return replaceCalls(s, prefixed(generatorExpr, generatorPattern, vars), func(c generatorCall) (string, error) {
+		if c.escaped {
+			return c.unescaped(vars), nil
+		}
+
+		v, err := callGenerator(c, vars)
+		if err != nil {
+			return "", err
+		}
+
+		str, err := varString(v)
+		if err != nil {
+			return "", err
+		}
+
+		if escape != nil {
+			str = escape(str)
+		}
+
+		return str, nil
+	})
+}
+
+// generateJSON replaces generator calls in a JSON document with generated values.
+//
+// Call that takes a whole JSON string is replaced with JSON value, so that numbers stay numbers.
+func generateJSON(body []byte, vars *shared.Vars) ([]byte, error) {
+	quoted := prefixed(`"`+generatorExpr+`"`, quotedGeneratorPattern, vars)
+
+	s, err := replaceCalls(string(body), quoted, func(c generatorCall) (string, error) {
+		// Escaped call is kept for the next replacement.
+		if c.escaped {
+			return c.call, nil
+		}
+
+		c.call = c.call[1 : len(c.call)-1]
+
+		v, err := callGenerator(c, vars)
+		if err != nil {
+			return "", err
+		}
+
+		j, err := marshalJSON(v)
+
+		return string(j), err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s, err = replaceCalls(s, prefixed(generatorExpr, generatorPattern, vars), func(c generatorCall) (string, error) {
+		if c.escaped {
+			return c.unescaped(vars), nil
+		}
+
+		v, err := callGenerator(c, vars)
+		if err != nil {
+			return "", err
+		}
+
+		str, err := varString(v)
+		if err != nil {
+			return "", err
+		}
+
+		// Escaping string to embed it in JSON string.
+		j, _ := marshalJSON(str) //nolint:errcheck // String is always marshaled.
+
+		return string(j[1 : len(j)-1]), nil
+	})
+
+	return []byte(s), err
+}
+
+// callGenerator invokes generator and stores result in a variable if it is assigned.
+//
+// Arguments are separated with commas.
+func callGenerator(c generatorCall, vars *shared.Vars) (interface{}, error) {
+	gen := generators[c.name]
+
+	var args []string
+
+	if strings.TrimSpace(c.args) != "" {
+		for _, a := range strings.Split(c.args, ",") {
+			args = append(args, strings.TrimSpace(a))
+		}
+	}
+
+	v, err := gen(args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s: %w", c.call, err)
+	}
+
+	if c.varName != "" && vars != nil {
+		vars.Set(varPrefix(vars)+c.varName, v)
+	}
+
+	return v, nil
+}
+
+// generateVars replaces generator calls and defined variables in a value of request.
+func generateVars(s string, vars *shared.Vars) (string, error) {
+	return generateAndExpand(s, vars, nil)
+}
+
+// generateURI replaces generator calls and defined variables in request URI, values are escaped in query.
+func generateURI(uri string, vars *shared.Vars) (string, error) {
+	return expandQuery(uri, vars, generateAndExpand)
+}
+
+func generateAndExpand(s string, vars *shared.Vars, escape func(string) string) (string, error) {
+	s, err := generate(s, vars, escape)
+	if err != nil {
+		return "", err
+	}
+
+	return expand(s, vars, escape)
+}
+
+// loadGeneratedBodyFromFile reads body of request or mocked response with generator calls replaced.
+func loadGeneratedBodyFromFile(filePath string, vars *shared.Vars) ([]byte, error) {
+	body, err := ioutil.ReadFile(filePath) // nolint:gosec // File inclusion via variable during tests.
+	if err != nil {
+		return nil, err
+	}
+
+	return loadGeneratedBody(body, vars)
+}
+
+// loadGeneratedBody prepares body of request or mocked response with generator calls replaced.
+func loadGeneratedBody(body []byte, vars *shared.Vars) ([]byte, error) {
+	var err error
+
+	if json5.Valid(body) {
+		if body, err = json5.Downgrade(body); err != nil {
+			return nil, fmt.Errorf("failed to downgrade JSON5 to JSON: %w", err)
+		}
+	}
+
+	if json.Valid(body) {
+		body, err = generateJSON(body, vars)
+	} else {
+		var str string
+
+		str, err = generate(string(body), vars, nil)
+		body = []byte(str)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loadBody(body, vars)
+}
+
+// varString formats variable value to be used in a string.
+func varString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		j, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+
+		return string(j), nil
+	}
+}
+
+func checkArgs(args []string, n int) error {
+	if len(args) != n {
+		return fmt.Errorf("%w: %d expected, %d received", errInvalidArguments, n, len(args))
+	}
+
+	return nil
+}
+
+func genUUID(args []string) (interface{}, error) {
+	if err := checkArgs(args, 0); err != nil {
+		return nil, err
+	}
+
+	u := make([]byte, 16)
+
+	if _, err := rand.Read(u); err != nil {
+		return nil, err
+	}
+
+	u[6] = (u[6] & 0x0f) | 0x40 // Version 4.
+	u[8] = (u[8] & 0x3f) | 0x80 // Variant RFC 4122.
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:]), nil
+}
+
+// genNow formats current time with a named layout (for example RFC3339) or a Go layout.
+//
+// Unix and UnixMilli produce integer timestamps.
+func genNow(args []string) (interface{}, error) {
+	if len(args) == 0 {
+		args = []string{"RFC3339"}
+	}
+
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	switch args[0] {
+	case "Unix":
+		return now.Unix(), nil
+	case "UnixMilli":
+		return now.UnixNano() / int64(time.Millisecond), nil
+	}
+
+	layout, found := timeLayouts[args[0]]
+	if !found {
+		layout = args[0]
+	}
+
+	return now.Format(layout), nil
+}
+
+// genRandInt produces random integer in range [min, max].
+func genRandInt(args []string) (interface{}, error) {
+	if err := checkArgs(args, 2); err != nil {
+		return nil, err
+	}
+
+	from, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidArguments, err)
+	}
+
+	to, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidArguments, err)
+	}
+
+	if to < from {
+		return nil, fmt.Errorf("%w: min %d is greater than max %d", errInvalidArguments, from, to)
+	}
+
+	// Size of range does not fit int64 for wide ranges.
+	size := new(big.Int).Sub(big.NewInt(to), big.NewInt(from))
+	size.Add(size, big.NewInt(1))
+
+	n, err := rand.Int(rand.Reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.Add(n, big.NewInt(from)).Int64(), nil
+}
+
+const randAlphabet = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// genRandString produces random alphanumeric string of a length.
+func genRandString(args []string) (interface{}, error) {
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("%w: invalid length %q", errInvalidArguments, args[0])
+	}
+
+	res := bytes.NewBuffer(make([]byte, 0, n))
+	size := big.NewInt(int64(len(randAlphabet)))
+
+	for i := 0; i < n; i++ {
+		k, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return nil, err
+		}
+
+		res.WriteByte(randAlphabet[k.Int64()])
+	}
+
+	return res.String(), nil
+}
+
+func genEnv(args []string) (interface{}, error) {
+	if err := checkArgs(args, 1); err != nil {
+		return nil, err
+	}
+
+	v, found := os.LookupEnv(args[0])
+	if !found {
+		return nil, fmt.Errorf("%w: %s", errMissingEnv, args[0])
+	}
+
+	return v, nil
+}
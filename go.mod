@@ -3,9 +3,14 @@ module github.com/bool64/httpdog
 go 1.13
 
 require (
+	github.com/PaesslerAG/jsonpath v0.1.1
 	github.com/bool64/dev v0.1.41
 	github.com/bool64/shared v0.1.3
 	github.com/cucumber/godog v0.12.0
+	github.com/getkin/kin-openapi v0.94.0
+	github.com/ghodss/yaml v1.0.1-0.20190212211648-25d852aebe32 // indirect
+	github.com/go-openapi/swag v0.19.14 // indirect
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.0
 	github.com/stretchr/testify v1.7.0
 	github.com/swaggest/assertjson v1.6.8
 	github.com/swaggest/rest v0.2.11
@@ -0,0 +1,404 @@
+package httpdog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// HAR writes HTTP exchanges of each scenario to a HAR 1.2 file that can be opened in browser devtools.
+//
+// Same instance can be used by Local and External to have application requests
+// and mocked upstream calls in one file, file is written once after the last of them finishes scenario.
+//
+//		har := &httpdog.HAR{Dir: "_testdata/har"}
+//		local.HAR = har
+//		external.HAR = har
+type HAR struct {
+	// Dir is a directory for HAR files, file is named after scenario in a directory of feature.
+	Dir string
+
+	mu       sync.Mutex
+	entries  []harEntry
+	scenario string
+	users    int
+}
+
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+
+	started time.Time
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// newHAREntry describes exchange, comment is a name of mocked service.
+func newHAREntry(req *http.Request, reqBody []byte, status int, header http.Header, respBody []byte,
+	started time.Time, duration time.Duration, comment string) harEntry {
+	ms := float64(duration.Microseconds()) / 1000
+
+	e := harEntry{
+		StartedDateTime: started.Format(time.RFC3339Nano),
+		Time:            ms,
+		Timings:         harTimings{Wait: ms},
+		Comment:         comment,
+		started:         started,
+		Request: harRequest{
+			Method:      req.Method,
+			URL:         req.URL.String(),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(req.Header),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(reqBody),
+		},
+		Response: harResponse{
+			Status:      status,
+			StatusText:  http.StatusText(status),
+			HTTPVersion: "HTTP/1.1",
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(header),
+			Content:     harBody(header.Get("Content-Type"), respBody),
+			HeadersSize: -1,
+			BodySize:    len(respBody),
+		},
+	}
+
+	for _, c := range req.Cookies() {
+		e.Request.Cookies = append(e.Request.Cookies, harNameValue{Name: c.Name, Value: c.Value})
+	}
+
+	for _, c := range (&http.Response{Header: header}).Cookies() {
+		e.Response.Cookies = append(e.Response.Cookies, harNameValue{Name: c.Name, Value: c.Value})
+	}
+
+	for _, p := range strings.Split(req.URL.RawQuery, "&") {
+		if p == "" {
+			continue
+		}
+
+		kv := strings.SplitN(p, "=", 2)
+		name, _ := url.QueryUnescape(kv[0]) // nolint:errcheck // Raw value is kept on error.
+
+		value := ""
+		if len(kv) == 2 {
+			value, _ = url.QueryUnescape(kv[1]) // nolint:errcheck // Raw value is kept on error.
+		}
+
+		e.Request.QueryString = append(e.Request.QueryString, harNameValue{Name: name, Value: value})
+	}
+
+	if len(reqBody) > 0 {
+		e.Request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(reqBody)}
+	}
+
+	return e
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	res := []harNameValue{}
+
+	for _, k := range sortedHeaderKeys(header) {
+		for _, v := range header[k] {
+			res = append(res, harNameValue{Name: k, Value: v})
+		}
+	}
+
+	return res
+}
+
+func sortedHeaderKeys(header http.Header) []string {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
+
+// harBody makes content of response, binary body is base64 encoded.
+func harBody(contentType string, body []byte) harContent {
+	c := harContent{Size: len(body), MimeType: contentType}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = contentType
+	}
+
+	if isTextMediaType(mediaType) || json.Valid(body) {
+		c.Text = string(body)
+	} else if len(body) > 0 {
+		c.Text = base64.StdEncoding.EncodeToString(body)
+		c.Encoding = "base64"
+	}
+
+	return c
+}
+
+func isTextMediaType(mediaType string) bool {
+	return mediaType == "" || strings.HasPrefix(mediaType, "text/") || isJSONMediaType(mediaType) ||
+		strings.HasSuffix(mediaType, "xml") || mediaType == "application/x-www-form-urlencoded"
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// start begins scenario for one of users of HAR, first user deletes entries of previous scenario.
+func (h *HAR) start(sc *godog.Scenario) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.users > 0 && h.scenario == sc.Id {
+		h.users++
+
+		return
+	}
+
+	h.scenario = sc.Id
+	h.entries = nil
+	h.users = 1
+}
+
+// finish ends scenario for one of users of HAR, last user writes the file.
+func (h *HAR) finish(sc *godog.Scenario) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.users--
+
+	if h.users > 0 {
+		return nil
+	}
+
+	return h.write(scenarioFile(sc))
+}
+
+func (h *HAR) add(e harEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.entries = append(h.entries, e)
+}
+
+// write saves entries of a scenario ordered by start time.
+func (h *HAR) write(name string) error {
+	var l harLog
+
+	l.Log.Version = "1.2"
+	l.Log.Creator = harCreator{Name: "httpdog", Version: "1"}
+	l.Log.Entries = append([]harEntry{}, h.entries...)
+
+	sort.SliceStable(l.Log.Entries, func(i, j int) bool {
+		return l.Log.Entries[i].started.Before(l.Log.Entries[j].started)
+	})
+
+	data, err := json.MarshalIndent(l, "", " ")
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(h.Dir, name+".har")
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(data, '\n'), 0o600)
+}
+
+// serviceReceivesRequestsFromHARFile adds expectations from entries of HAR file.
+//
+// Entries that are commented with service name are used if there are any, otherwise all entries are used.
+func (e *External) serviceReceivesRequestsFromHARFile(service string, filePath *godog.DocString) error {
+	if _, ok := e.mocks[service]; !ok {
+		return fmt.Errorf("%w: %q", errNoMockForService, service)
+	}
+
+	if pending := e.pending[service]; pending.Method != "" {
+		return fmt.Errorf("%w in %s for %s %s", errUndefinedResponse, service, pending.Method, pending.RequestURI)
+	}
+
+	data, err := ioutil.ReadFile(strings.TrimSpace(filePath.Content))
+	if err != nil {
+		return err
+	}
+
+	var l harLog
+
+	if err := json.Unmarshal(data, &l); err != nil {
+		return fmt.Errorf("failed to decode HAR file: %w", err)
+	}
+
+	entries := l.Log.Entries
+
+	var own []harEntry
+
+	for _, entry := range entries {
+		if entry.Comment == service {
+			own = append(own, entry)
+		}
+	}
+
+	if len(own) > 0 {
+		entries = own
+	}
+
+	for _, entry := range entries {
+		if err := e.expectHAREntry(service, entry); err != nil {
+			return fmt.Errorf("invalid HAR entry %s %s: %w", entry.Request.Method, entry.Request.URL, err)
+		}
+	}
+
+	return nil
+}
+
+func (e *External) expectHAREntry(service string, entry harEntry) error {
+	u, err := url.Parse(entry.Request.URL)
+	if err != nil {
+		return err
+	}
+
+	pending := exp{}
+	pending.Method = entry.Request.Method
+	pending.RequestURI = u.RequestURI()
+
+	if entry.Request.PostData != nil && entry.Request.PostData.Text != "" {
+		pending.RequestBody = []byte(entry.Request.PostData.Text)
+	}
+
+	pending.ResponseHeader = make(map[string]string, len(entry.Response.Headers))
+
+	// Response header may have multiple values.
+	header := make(http.Header, len(entry.Response.Headers))
+
+	for _, h := range entry.Response.Headers {
+		name := http.CanonicalHeaderKey(h.Name)
+
+		// Content of HAR entry is decoded, so it does not match encoding and length of original body.
+		if skippedHeaders[name] || name == "Content-Encoding" {
+			continue
+		}
+
+		if _, found := pending.ResponseHeader[name]; !found {
+			pending.ResponseHeader[name] = h.Value
+		}
+
+		header.Add(name, h.Value)
+	}
+
+	pending.behavior = &behavior{header: header}
+
+	body := []byte(entry.Response.Content.Text)
+
+	if entry.Response.Content.Encoding == "base64" {
+		if body, err = base64.StdEncoding.DecodeString(entry.Response.Content.Text); err != nil {
+			return err
+		}
+	}
+
+	e.pending[service] = pending
+
+	return e.serviceRespondsWithStatusAndPreparedBody(service, strconv.Itoa(entry.Response.Status), body)
+}
+
+// receivedRequest keeps request of mocked service as it was received.
+func receivedRequest(req *http.Request) (*http.Request, []byte) {
+	body, err := ioutil.ReadAll(req.Body)
+	if err == nil {
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	r := req.Clone(req.Context())
+	r.URL = &url.URL{Scheme: "http", Host: req.Host, Path: req.URL.Path, RawQuery: req.URL.RawQuery}
+
+	return r, body
+}
+
+// recordHAR adds exchange of mocked service.
+func (e *External) recordHAR(service string, req *http.Request, reqBody []byte, rec *httptest.ResponseRecorder,
+	started time.Time) {
+	e.HAR.add(newHAREntry(req, reqBody, rec.Code, rec.Header(), rec.Body.Bytes(), started, time.Since(started), service))
+}
@@ -0,0 +1,121 @@
+package httpdog
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/PaesslerAG/jsonpath"
+	"github.com/cucumber/godog"
+	"github.com/swaggest/assertjson/json5"
+)
+
+var errUnexpectedJSONPathValue = errors.New("unexpected value at JSON path")
+
+func (l *Local) iShouldHaveResponseWithJSONPathEqualTo(path, value string) error {
+	return l.poll(func() error {
+		data, err := l.responseJSON()
+		if err != nil {
+			return err
+		}
+
+		return l.checkJSONPath(data, path, value)
+	})
+}
+
+func (l *Local) iShouldHaveResponseWithJSONPaths(table *godog.Table) error {
+	for _, row := range table.Rows {
+		if len(row.Cells) != 2 {
+			return fmt.Errorf("%w: 2 cells expected, %d received", errInvalidTable, len(row.Cells))
+		}
+	}
+
+	return l.poll(func() error {
+		data, err := l.responseJSON()
+		if err != nil {
+			return err
+		}
+
+		var errs []string
+
+		for _, row := range table.Rows {
+			if err := l.checkJSONPath(data, row.Cells[0].Value, row.Cells[1].Value); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		if len(errs) > 0 {
+			return errors.New(strings.Join(errs, ",\n"))
+		}
+
+		return nil
+	})
+}
+
+// responseJSON returns decoded JSON body of response.
+func (l *Local) responseJSON() (interface{}, error) {
+	resp, err := l.response()
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+
+	if err := json.Unmarshal(resp.respBody, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON response body: %w", err)
+	}
+
+	return data, nil
+}
+
+// checkJSONPath compares value at JSON path with expected value.
+//
+// Expected value is used as JSON5 unless it is invalid or actual value is a string.
+func (l *Local) checkJSONPath(data interface{}, path, expected string) error {
+	actual, err := jsonpath.Get(path, data)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate JSON path %s: %w", path, err)
+	}
+
+	if expected == l.JSONComparer.IgnoreDiff {
+		return nil
+	}
+
+	act, err := marshalJSON(actual)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value at JSON path %s: %w", path, err)
+	}
+
+	var exp []byte
+
+	if _, ok := actual.(string); ok || !json5.Valid([]byte(expected)) {
+		exp, err = marshalJSON(expected)
+	} else {
+		exp, err = json5.Downgrade([]byte(expected))
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to prepare expected value for JSON path %s: %w", path, err)
+	}
+
+	if err := l.JSONComparer.FailNotEqual(exp, act); err != nil {
+		return fmt.Errorf("%w %s, expected: %s, received: %s", errUnexpectedJSONPathValue, path, exp, act)
+	}
+
+	return nil
+}
+
+// marshalJSON encodes value without HTML escaping to keep messages readable.
+func marshalJSON(v interface{}) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
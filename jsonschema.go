@@ -0,0 +1,108 @@
+package httpdog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cucumber/godog"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+var errSchemaViolation = errors.New("response body does not match JSON schema")
+
+func (l *Local) iShouldHaveResponseMatchingJSONSchema(schemaDoc *godog.DocString) error {
+	schema, err := loadBody([]byte(schemaDoc.Content), l.JSONComparer.Vars)
+	if err != nil {
+		return err
+	}
+
+	return l.checkJSONSchema("schema.json", schema)
+}
+
+func (l *Local) iShouldHaveResponseMatchingJSONSchemaFromFile(filePath *godog.DocString) error {
+	schema, err := loadBodyFromFile(filePath.Content, l.JSONComparer.Vars)
+	if err != nil {
+		return err
+	}
+
+	// Absolute location allows relative references to other schema files.
+	location, err := filepath.Abs(filePath.Content)
+	if err != nil {
+		return err
+	}
+
+	return l.checkJSONSchema(location, schema)
+}
+
+func (l *Local) checkJSONSchema(location string, schema []byte) error {
+	s, err := compileJSONSchema(location, schema)
+	if err != nil {
+		return err
+	}
+
+	return l.poll(func() error {
+		data, err := l.responseJSON()
+		if err != nil {
+			return err
+		}
+
+		return validateJSONSchema(s, data)
+	})
+}
+
+// compileJSONSchema compiles draft-07 or 2020-12 (default) schema.
+func compileJSONSchema(location string, schema []byte) (*jsonschema.Schema, error) {
+	c := jsonschema.NewCompiler()
+
+	if err := c.AddResource(location, bytes.NewReader(schema)); err != nil {
+		return nil, fmt.Errorf("failed to load JSON schema: %w", err)
+	}
+
+	s, err := c.Compile(location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile JSON schema: %w", err)
+	}
+
+	return s, nil
+}
+
+// validateJSONSchema returns error with all violations found in data.
+func validateJSONSchema(s *jsonschema.Schema, data interface{}) error {
+	err := s.Validate(data)
+	if err == nil {
+		return nil
+	}
+
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err
+	}
+
+	return fmt.Errorf("%w:\n%s", errSchemaViolation, strings.Join(schemaViolations(ve), "\n"))
+}
+
+// schemaViolations lists leaf validation errors with instance locations.
+func schemaViolations(ve *jsonschema.ValidationError) []string {
+	if len(ve.Causes) == 0 {
+		location := ve.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+
+		return []string{location + ": " + ve.Message}
+	}
+
+	var res []string
+
+	for _, c := range ve.Causes {
+		res = append(res, schemaViolations(c)...)
+	}
+
+	sort.Strings(res)
+
+	return res
+}
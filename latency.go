@@ -0,0 +1,90 @@
+package httpdog
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	errSlowResponse       = errors.New("response is too slow")
+	errSlowOtherResponses = errors.New("other responses are too slow")
+	errNoOtherResponses   = errors.New("no other responses")
+	errInvalidPercentile  = errors.New("invalid percentile")
+)
+
+func (l *Local) iShouldHaveResponseWithin(limit string) error {
+	d, err := time.ParseDuration(limit)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+
+	return l.poll(func() error {
+		resp, err := l.response()
+		if err != nil {
+			return err
+		}
+
+		if resp.duration > d {
+			return fmt.Errorf("%w: %s, expected within %s", errSlowResponse, roundDuration(resp.duration), d)
+		}
+
+		return nil
+	})
+}
+
+// iShouldHaveOtherResponsesPercentileWithin checks latency percentile of all concurrent responses except the main one.
+//
+// Unlike status, header and body expectations of other responses, status of responses is not taken into account.
+func (l *Local) iShouldHaveOtherResponsesPercentileWithin(percentile int, limit string) error {
+	d, err := time.ParseDuration(limit)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %w", err)
+	}
+
+	if percentile < 1 || percentile > 100 {
+		return fmt.Errorf("%w: %d", errInvalidPercentile, percentile)
+	}
+
+	return l.poll(func() error {
+		resp, err := l.response()
+		if err != nil {
+			return err
+		}
+
+		var durations []time.Duration
+
+		for _, ex := range l.exchanges {
+			if ex.req != resp.req {
+				durations = append(durations, ex.duration)
+			}
+		}
+
+		if len(durations) == 0 {
+			return errNoOtherResponses
+		}
+
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+		p := durations[int(math.Ceil(float64(percentile)/100*float64(len(durations))))-1]
+		if p <= d {
+			return nil
+		}
+
+		measured := make([]string, 0, len(durations))
+		for _, dur := range durations {
+			measured = append(measured, roundDuration(dur).String())
+		}
+
+		return fmt.Errorf("%w: p%d %s of %d responses, expected within %s, durations: %s",
+			errSlowOtherResponses, percentile, roundDuration(p), len(durations), d, strings.Join(measured, ", "))
+	})
+}
+
+// roundDuration keeps duration readable in messages.
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
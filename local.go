@@ -8,35 +8,176 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/bool64/shared"
 	"github.com/cucumber/godog"
+	"github.com/swaggest/assertjson"
 	"github.com/swaggest/assertjson/json5"
-	"github.com/swaggest/rest/resttest"
 )
 
 // NewLocal creates an instance of step-driven HTTP client.
-func NewLocal(baseURL string) *Local {
-	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
-		baseURL = "http://" + baseURL
-	}
-
-	baseURL = strings.TrimRight(baseURL, "/")
-
+//
+// Options can configure Local before use, for example to validate requests and responses against OpenAPI.
+//
+//		local := httpdog.NewLocal(baseURL, func(l *httpdog.Local) {
+//			l.OpenAPI = spec
+//		})
+func NewLocal(baseURL string, options ...func(l *Local)) *Local {
 	l := Local{
-		Client: resttest.NewClient(baseURL),
+		JSONComparer: assertjson.Comparer{IgnoreDiff: assertjson.IgnoreDiff},
 	}
 
+	l.SetBaseURL(baseURL)
+	l.reset()
+
 	l.JSONComparer.Vars = &shared.Vars{}
 
+	for _, option := range options {
+		option(&l)
+	}
+
 	return &l
 }
 
+// SetBaseURL changes baseURL configured with constructor.
+func (l *Local) SetBaseURL(baseURL string) {
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		baseURL = "http://" + baseURL
+	}
+
+	l.baseURL = strings.TrimRight(baseURL, "/")
+}
+
 // Local is step-driven HTTP client for application local HTTP service.
 type Local struct {
-	*resttest.Client
+	// ConcurrencyLevel is a number of requests to check idempotency, default is 10.
+	ConcurrencyLevel int
+
+	// JSONComparer compares JSON bodies and keeps variables.
+	JSONComparer assertjson.Comparer
+
+	// Headers and Cookies are sent with every request, values of request steps take precedence.
+	Headers map[string]string
+	Cookies map[string]string
+
+	// OpenAPI is an optional document to validate every request and response against.
+	//
+	// Contract violations fail the step that received the response.
+	OpenAPI *OpenAPI
+
+	// HAR is an optional writer of exchanges of each scenario.
+	HAR *HAR
+
+	// MaskCredentials hides values of Authorization, Proxy-Authorization and Cookie headers
+	// in curl command of failure, by default command has real values to be ready to paste.
+	MaskCredentials bool
+
+	baseURL string
+	req     request
+	polling *polling
+
+	// exchanges are all round trips of sent request, resp and other are main and other responses among them.
+	exchanges     []exchange
+	resp          *exchange
+	other         *exchange
+	otherExpected bool
+
+	// validated is a number of exchanges checked against OpenAPI.
+	validated int
+}
+
+// reset deletes client state.
+func (l *Local) reset() {
+	l.req = request{
+		headers: map[string]string{},
+		cookies: map[string]string{},
+	}
+
+	l.polling = nil
+
+	l.discard()
+}
+
+// checked finalizes result of a step that might have sent the request.
+//
+// Failure of concurrent requests is amended with distribution of responses,
+// received responses are checked against OpenAPI contract regardless of step result.
+func (l *Local) checked(err error) error {
+	if err != nil && len(l.exchanges) > 1 {
+		err = fmt.Errorf("%w\n%s", err, distribution(l.exchanges))
+	}
+
+	if l.OpenAPI == nil {
+		return err
+	}
+
+	for ; l.validated < len(l.exchanges); l.validated++ {
+		if verr := l.OpenAPI.checkExchange(l.exchanges[l.validated]); verr != nil {
+			l.validated = len(l.exchanges)
+
+			if err == nil {
+				return verr
+			}
+
+			return fmt.Errorf("%w\n%s", err, verr)
+		}
+	}
+
+	return err
+}
+
+// response sends request if it was not sent yet and returns details of main response.
+func (l *Local) response() (exchange, error) {
+	if err := l.checked(l.send()); err != nil {
+		return exchange{}, err
+	}
+
+	if l.resp == nil {
+		return exchange{}, errNoResponse
+	}
+
+	return *l.resp, nil
+}
+
+// expectResponse sends request if it was not sent yet and checks main response.
+func (l *Local) expectResponse(check func(ex exchange) error) error {
+	err := l.send()
+	if err == nil {
+		err = check(*l.resp)
+	}
+
+	return l.checked(err)
+}
+
+// expectOtherResponses sends request if it was not sent yet and checks other responses of concurrent requests.
+func (l *Local) expectOtherResponses(check func(ex exchange) error) error {
+	l.otherExpected = true
+
+	err := l.send()
+
+	switch {
+	case err != nil:
+	case l.other == nil:
+		err = errSameStatus
+	default:
+		err = check(*l.other)
+	}
+
+	return l.checked(err)
+}
+
+// checkOtherResponses fails if other responses were received, but not expected by steps.
+func (l *Local) checkOtherResponses() error {
+	if l.otherExpected || l.other == nil {
+		return nil
+	}
+
+	return checkStatus(l.resp.resp.StatusCode, *l.other)
 }
 
 // RegisterSteps adds HTTP server steps to godog scenario context.
@@ -56,6 +197,36 @@ type Local struct {
 //
 //		And I request HTTP endpoint with cookie "name: value"
 //
+// Query parameters, form data and headers can be supplied with tables, values are URL encoded when needed.
+// Repeated keys are allowed for query parameters and form data.
+//
+//		And I request HTTP endpoint with query parameters
+//		| tag  | red      |
+//		| tag  | green    |
+//		| user | $user_id |
+//
+//		And I request HTTP endpoint with form data
+//		| name | John Doe |
+//
+//		And I request HTTP endpoint with headers
+//		| X-Foo | bar |
+//		| X-Bar | baz |
+//
+// Multipart form data can be supplied with a table of fields. Value prefixed with @ is a path to file attachment,
+// @@ prefix escapes literal @ in value, optional third cell defines content type of a part.
+//
+//		And I request HTTP endpoint with multipart form data
+//		| title  | Report              |          |
+//		| author | @@john              |          |
+//		| report | @path/to/report.csv | text/csv |
+//		| photo  | @path/to/photo.png  |          |
+//
+// Variables are expanded in URI, header, cookie and table values, values are URL encoded in URI query.
+// Unknown variable fails the step, doubled prefix makes a literal value, e.g. "$$top" is sent as "$top".
+//
+//		When I request HTTP endpoint with method "GET" and URI "/user/$user_id/orders?$$top=10&skip=$skip"
+//		And I request HTTP endpoint with header "X-User-Id: $user_id"
+//
 // Optionally request body can be configured. If body is a valid JSON5 payload, it will be converted to JSON before use.
 // Otherwise, body is used as is.
 //
@@ -74,6 +245,22 @@ type Local struct {
 //		path/to/file.json5
 //		"""
 //
+// Generators produce unique values in URI, header, cookie, table values and body of request, they are also called
+// in expected response body (and in bodies of External expectations), generated value can be stored in a variable.
+// Available generators are $uuid(), $now(RFC3339), $randInt(1,100), $randString(12) and $env(NAME).
+// Arguments are separated with commas, calls of unknown generators are kept as is,
+// doubled prefix keeps a literal call, e.g. $$uuid().
+//
+//		And I request HTTP endpoint with body
+//		"""
+//		{"id":"$user_id=$uuid()","email":"user-$randString(8)@example.com","age":"$randInt(18,99)"}
+//		"""
+//
+// Endpoints with eventual consistency can be polled. Request is repeated until all expectations of following steps
+// are met or timeout runs out, failure shows the mismatch of the last attempt.
+//
+//		And I request HTTP endpoint until response matches within "5s" every "200ms"
+//
 // If endpoint is capable of handling duplicated requests, you can check it for idempotency. This would send multiple
 // requests simultaneously and check
 //   * if all responses are similar or (all successful like GET),
@@ -84,6 +271,13 @@ type Local struct {
 //
 //		And I concurrently request idempotent HTTP endpoint
 //
+// Number of requests can also be defined in the step (at least 2), start of requests can be spread randomly
+// with a jitter.
+// Failure shows distribution of statuses and bodies of all responses.
+//
+//		And I concurrently request idempotent HTTP endpoint "20" times
+//		And I concurrently request idempotent HTTP endpoint "20" times with jitter "100ms"
+//
 //
 // Response Expectations
 //
@@ -109,12 +303,45 @@ type Local struct {
 //		path/to/file.json
 //		"""
 //
+// Separate values of JSON response can be checked with JSON path expressions. Expected value is treated as JSON5
+// unless the actual value is a string. Variables and `"<ignore-diff>"` are supported as in body expectations.
+//
+//		And I should have response with JSON path "$.items[0].id" equal to "42"
+//
+// Multiple JSON paths can be checked with a table of paths and values.
+//
+//		And I should have response with JSON paths
+//		| $.items[0].id   | 42         |
+//		| $.items[0].name | foo        |
+//		| $.created_at    | $created   |
+//
+// Shape of JSON response can be checked with a JSON schema, draft-07 and 2020-12 (default) are supported.
+// All found violations are reported with their instance paths.
+//
+//		And I should have response matching JSON schema
+//		"""
+//		{"type":"object","required":["id"],"properties":{"id":{"type":"integer"}}}
+//		"""
+//
+// JSON schema can be provided from file, relative references to other files are resolved from its location.
+//
+//		And I should have response matching JSON schema from file
+//		"""
+//		path/to/schema.json
+//		"""
+//
 // Status can be defined with either phrase or numeric code. Also you can set response header expectations.
 //
 //		Then I should have response with status "OK"
 //		And I should have response with header "Content-Type: application/json"
 //		And I should have response with header "X-Header: abc"
 //
+// Values of response header or cookie can be captured into variables to be used in later steps.
+// If variable is already defined, captured value must be equal to it.
+//
+//		And I should have response with header "Location" captured as "$location"
+//		And I should have response with cookie "session" captured as "$session"
+//
 // In an idempotent mode you can set expectations for statuses of other responses.
 //
 //		Then I should have response with status "204"
@@ -135,9 +362,33 @@ type Local struct {
 //		"""
 //		path/to/file.json
 //		"""
+//
+// Response latency can be limited, failure shows measured durations.
+//
+//		And I should have response within "300ms"
+//
+// In an idempotent mode latency of other responses can be limited with a percentile.
+//
+//		And I should have other responses p95 within "500ms"
+//
+// Unlike status, header and body steps, where other responses are those of another kind (status),
+// latency step considers all concurrent responses except the main one, regardless of their status.
+//
+// Failure of response expectation shows curl command to reproduce the request (after variables are expanded)
+// and received response status, headers and body (truncated), credentials can be masked with Local.MaskCredentials.
 func (l *Local) RegisterSteps(s *godog.ScenarioContext) {
-	s.Before(func(ctx context.Context, _ *godog.Scenario) (context.Context, error) {
-		l.Reset()
+	l.hooks(s, "")
+	l.steps(s, "HTTP endpoint", "response", "other responses")
+}
+
+// hooks resets state before scenario and checks other responses after scenario.
+func (l *Local) hooks(s *godog.ScenarioContext, service string) {
+	s.Before(func(ctx context.Context, sc *godog.Scenario) (context.Context, error) {
+		l.reset()
+
+		if l.HAR != nil {
+			l.HAR.start(sc)
+		}
 
 		if l.JSONComparer.Vars != nil {
 			l.JSONComparer.Vars.Reset()
@@ -147,44 +398,79 @@ func (l *Local) RegisterSteps(s *godog.ScenarioContext) {
 	})
 
 	s.After(func(ctx context.Context, sc *godog.Scenario, err error) (context.Context, error) {
-		if err := l.CheckUnexpectedOtherResponses(); err != nil {
-			err = fmt.Errorf("no other responses expected: %w", err)
+		if l.HAR != nil {
+			if err := l.HAR.finish(sc); err != nil {
+				return ctx, fmt.Errorf("failed to write HAR file: %w", err)
+			}
+		}
 
-			return ctx, err
+		if err := l.checkOtherResponses(); err != nil {
+			if service != "" {
+				return ctx, fmt.Errorf("no other responses expected for %s: %w", service, err)
+			}
+
+			return ctx, fmt.Errorf("no other responses expected: %w", err)
 		}
 
 		return ctx, nil
 	})
+}
 
-	s.Step(`^I request HTTP endpoint with method "([^"]*)" and URI (.*)$`, l.iRequestWithMethodAndURI)
-	s.Step(`^I request HTTP endpoint with body$`, l.iRequestWithBody)
-	s.Step(`^I request HTTP endpoint with body from file$`, l.iRequestWithBodyFromFile)
-	s.Step(`^I request HTTP endpoint with header "([^"]*): ([^"]*)"$`, l.iRequestWithHeader)
-	s.Step(`^I request HTTP endpoint with cookie "([^"]*): ([^"]*)"$`, l.iRequestWithCookie)
-
-	s.Step(`^I concurrently request idempotent HTTP endpoint$`, l.iRequestWithConcurrency)
-
-	s.Step(`^I should have response with status "([^"]*)"$`, l.iShouldHaveResponseWithStatus)
-	s.Step(`^I should have response with header "([^"]*): ([^"]*)"$`, l.iShouldHaveResponseWithHeader)
-	s.Step(`^I should have response with body from file$`, l.iShouldHaveResponseWithBodyFromFile)
-	s.Step(`^I should have response with body$`, l.iShouldHaveResponseWithBody)
-
-	s.Step(`^I should have other responses with status "([^"]*)"$`, l.iShouldHaveOtherResponsesWithStatus)
-	s.Step(`^I should have other responses with header "([^"]*): ([^"]*)"$`, l.iShouldHaveOtherResponsesWithHeader)
-	s.Step(`^I should have other responses with body$`, l.iShouldHaveOtherResponsesWithBody)
-	s.Step(`^I should have other responses with body from file$`, l.iShouldHaveOtherResponsesWithBodyFromFile)
+// steps adds steps with given phrases of endpoint, response and other responses.
+func (l *Local) steps(s *godog.ScenarioContext, endpoint, response, other string) {
+	s.Step(`^I request `+endpoint+` with method "([^"]*)" and URI (.*)$`, l.iRequestWithMethodAndURI)
+	s.Step(`^I request `+endpoint+` with body$`, l.iRequestWithBody)
+	s.Step(`^I request `+endpoint+` with body from file$`, l.iRequestWithBodyFromFile)
+	s.Step(`^I request `+endpoint+` with header "([^"]*): ([^"]*)"$`, l.iRequestWithHeader)
+	s.Step(`^I request `+endpoint+` with cookie "([^"]*): ([^"]*)"$`, l.iRequestWithCookie)
+	s.Step(`^I request `+endpoint+` with query parameters$`, l.iRequestWithQueryParameters)
+	s.Step(`^I request `+endpoint+` with form data$`, l.iRequestWithFormData)
+	s.Step(`^I request `+endpoint+` with multipart form data$`, l.iRequestWithMultipartFormData)
+	s.Step(`^I request `+endpoint+` with headers$`, l.iRequestWithHeaders)
+
+	s.Step(`^I concurrently request idempotent `+endpoint+`$`, l.iRequestWithConcurrency)
+	s.Step(`^I concurrently request idempotent `+endpoint+` "(\d+)" times$`, l.iRequestWithConcurrencyTimes)
+	s.Step(`^I concurrently request idempotent `+endpoint+` "(\d+)" times with jitter "([^"]*)"$`,
+		l.iRequestWithConcurrencyTimesWithJitter)
+	s.Step(`^I request `+endpoint+` until response matches within "([^"]*)" every "([^"]*)"$`,
+		l.iRequestUntilResponseMatches)
+
+	s.Step(`^I should have `+response+` with status "([^"]*)"$`, l.iShouldHaveResponseWithStatus)
+	s.Step(`^I should have `+response+` with header "([^"]*): ([^"]*)"$`, l.iShouldHaveResponseWithHeader)
+	s.Step(`^I should have `+response+` with header "([^"]*)" captured as "([^"]*)"$`,
+		l.iShouldHaveResponseWithHeaderCapturedAs)
+	s.Step(`^I should have `+response+` with cookie "([^"]*)" captured as "([^"]*)"$`,
+		l.iShouldHaveResponseWithCookieCapturedAs)
+	s.Step(`^I should have `+response+` within "([^"]*)"$`, l.iShouldHaveResponseWithin)
+	s.Step(`^I should have `+response+` with body from file$`, l.iShouldHaveResponseWithBodyFromFile)
+	s.Step(`^I should have `+response+` with body$`, l.iShouldHaveResponseWithBody)
+	s.Step(`^I should have `+response+` with JSON path "([^"]*)" equal to "([^"]*)"$`,
+		l.iShouldHaveResponseWithJSONPathEqualTo)
+	s.Step(`^I should have `+response+` with JSON paths$`, l.iShouldHaveResponseWithJSONPaths)
+	s.Step(`^I should have `+response+` matching JSON schema$`, l.iShouldHaveResponseMatchingJSONSchema)
+	s.Step(`^I should have `+response+` matching JSON schema from file$`,
+		l.iShouldHaveResponseMatchingJSONSchemaFromFile)
+
+	s.Step(`^I should have `+other+` with status "([^"]*)"$`, l.iShouldHaveOtherResponsesWithStatus)
+	s.Step(`^I should have `+other+` with header "([^"]*): ([^"]*)"$`, l.iShouldHaveOtherResponsesWithHeader)
+	s.Step(`^I should have `+other+` with body$`, l.iShouldHaveOtherResponsesWithBody)
+	s.Step(`^I should have `+other+` with body from file$`, l.iShouldHaveOtherResponsesWithBodyFromFile)
+	s.Step(`^I should have `+other+` p(\d+) within "([^"]*)"$`, l.iShouldHaveOtherResponsesPercentileWithin)
 }
 
 func (l *Local) iRequestWithMethodAndURI(method, uri string) error {
-	if err := l.CheckUnexpectedOtherResponses(); err != nil {
+	if err := l.checkOtherResponses(); err != nil {
 		return fmt.Errorf("unexpected other responses for previous request: %w", err)
 	}
 
-	uri = strings.Trim(uri, `"`)
+	uri, err := generateURI(strings.Trim(uri, `"`), l.JSONComparer.Vars)
+	if err != nil {
+		return err
+	}
 
-	l.Reset()
-	l.WithMethod(method)
-	l.WithURI(uri)
+	l.reset()
+	l.req.method = method
+	l.req.uri = uri
 
 	return nil
 }
@@ -221,34 +507,137 @@ func loadBody(body []byte, vars *shared.Vars) ([]byte, error) {
 	return body, nil
 }
 
+// varExpr matches variable name after a prefix, doubled prefix escapes variable.
+const varExpr = `%[1]s(?:%[1]s)?[A-Za-z_][A-Za-z0-9_]*`
+
+// varPattern matches variables with default prefix.
+var varPattern = regexp.MustCompile(fmt.Sprintf(varExpr, regexp.QuoteMeta("$")))
+
+// customPatterns keeps compiled expressions of custom variable prefixes.
+var customPatterns sync.Map
+
+// prefixed returns compiled expression with variable prefix of vars in place of %[1]s.
+func prefixed(expr string, defaultPattern *regexp.Regexp, vars *shared.Vars) *regexp.Regexp {
+	prefix := varPrefix(vars)
+	if prefix == "$" {
+		return defaultPattern
+	}
+
+	key := prefix + " " + expr
+	if re, ok := customPatterns.Load(key); ok {
+		return re.(*regexp.Regexp)
+	}
+
+	re := regexp.MustCompile(fmt.Sprintf(expr, regexp.QuoteMeta(prefix)))
+	customPatterns.Store(key, re)
+
+	return re
+}
+
+// expandVars replaces variables in a string with their values.
+//
+// Unknown variable is an error, doubled prefix (for example $$filter) is replaced with a single one.
+func expandVars(s string, vars *shared.Vars) (string, error) {
+	return expand(s, vars, nil)
+}
+
+// expandURI replaces variables in request URI, values are escaped in query.
+func expandURI(uri string, vars *shared.Vars) (string, error) {
+	return expandQuery(uri, vars, expand)
+}
+
+// expandQuery applies expansion to path and query of request URI, values are escaped in query.
+func expandQuery(uri string, vars *shared.Vars,
+	expand func(s string, vars *shared.Vars, escape func(string) string) (string, error)) (string, error) {
+	path, query := splitURI(uri)
+
+	path, err := expand(path, vars, nil)
+	if err != nil || !strings.Contains(uri, "?") {
+		return path, err
+	}
+
+	query, err = expand(query, vars, url.QueryEscape)
+
+	return path + "?" + query, err
+}
+
+// expand replaces variables with values, optional escape is applied to values.
+func expand(s string, vars *shared.Vars, escape func(string) string) (string, error) {
+	if vars == nil {
+		return s, nil
+	}
+
+	var err error
+
+	prefix := varPrefix(vars)
+
+	s = prefixed(varExpr, varPattern, vars).ReplaceAllStringFunc(s, func(name string) string {
+		if strings.HasPrefix(name, prefix+prefix) {
+			return name[len(prefix):]
+		}
+
+		v, found := vars.Get(name)
+		if !found {
+			if err == nil {
+				err = fmt.Errorf("%w: %s", errUnknownVariable, name)
+			}
+
+			return name
+		}
+
+		str, verr := varString(v)
+		if verr != nil && err == nil {
+			err = fmt.Errorf("failed to marshal var %s (%v): %w", name, v, verr)
+		}
+
+		if escape != nil {
+			str = escape(str)
+		}
+
+		return str
+	})
+
+	return s, err
+}
+
 func (l *Local) iRequestWithBodyFromFile(filePath *godog.DocString) error {
-	body, err := loadBodyFromFile(filePath.Content, l.JSONComparer.Vars)
+	body, err := loadGeneratedBodyFromFile(filePath.Content, l.JSONComparer.Vars)
 
 	if err == nil {
-		l.WithBody(body)
+		l.req.body = body
 	}
 
 	return err
 }
 
 func (l *Local) iRequestWithBody(bodyDoc *godog.DocString) error {
-	body, err := loadBody([]byte(bodyDoc.Content), l.JSONComparer.Vars)
+	body, err := loadGeneratedBody([]byte(bodyDoc.Content), l.JSONComparer.Vars)
 
 	if err == nil {
-		l.WithBody(body)
+		l.req.body = body
 	}
 
 	return err
 }
 
 func (l *Local) iRequestWithHeader(key, value string) error {
-	l.WithHeader(key, value)
+	value, err := generateVars(value, l.JSONComparer.Vars)
+	if err != nil {
+		return err
+	}
+
+	l.req.headers[http.CanonicalHeaderKey(key)] = value
 
 	return nil
 }
 
 func (l *Local) iRequestWithCookie(name, value string) error {
-	l.WithCookie(name, value)
+	value, err := generateVars(value, l.JSONComparer.Vars)
+	if err != nil {
+		return err
+	}
+
+	l.req.cookies[name] = value
 
 	return nil
 }
@@ -258,6 +647,17 @@ var (
 	errNoMockForService  = errors.New("no mock for service")
 	errUndefinedRequest  = errors.New("undefined request (missing `receives <METHOD> request` step)")
 	errUndefinedResponse = errors.New("undefined response (missing `responds with status <STATUS>` step)")
+	errInvalidTable      = errors.New("invalid table")
+	errUnknownVariable   = errors.New("unknown variable")
+	errInvalidVariable   = errors.New("invalid variable name")
+	errMissingHeader     = errors.New("missing response header")
+	errMissingCookie     = errors.New("missing response cookie")
+	errVariableMismatch  = errors.New("unexpected value of variable")
+	errUnexpectedOrder   = errors.New("unexpected order of external calls")
+	errUnexpectedState   = errors.New("unexpected state of service")
+	errUnexpectedStatus  = errors.New("unexpected response status")
+	errUnexpectedBody    = errors.New("unexpected body")
+	errEmptyBody         = errors.New("received empty body")
 )
 
 func statusCode(statusOrCode string) (int, error) {
@@ -278,13 +678,60 @@ func statusCode(statusOrCode string) (int, error) {
 	return int(code), nil
 }
 
+// checkStatus compares status code of response.
+func checkStatus(code int, ex exchange) error {
+	if ex.resp.StatusCode != code {
+		return fmt.Errorf("%w, expected: %d (%s), received: %d (%s)", errUnexpectedStatus,
+			code, http.StatusText(code), ex.resp.StatusCode, http.StatusText(ex.resp.StatusCode))
+	}
+
+	return nil
+}
+
+// checkHeader compares value of response header, expected value can have variables and "<ignore-diff>".
+func (l *Local) checkHeader(key, value string, ex exchange) error {
+	expected, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	received, err := json.Marshal(ex.resp.Header.Get(key))
+	if err != nil {
+		return err
+	}
+
+	return l.JSONComparer.FailNotEqual(expected, received)
+}
+
+// checkBody compares response body, JSON bodies are compared with JSONComparer, others as is.
+func (l *Local) checkBody(expected []byte, ex exchange) error {
+	received := ex.respBody
+
+	switch {
+	case len(received) == 0 && len(expected) == 0:
+		return nil
+	case len(received) == 0:
+		return errEmptyBody
+	case json.Valid(expected) && json.Valid(received):
+		return l.JSONComparer.FailNotEqual(expected, received)
+	case !bytes.Equal(expected, received):
+		return fmt.Errorf("%w, expected: %s, received: %s", errUnexpectedBody, string(expected), string(received))
+	}
+
+	return nil
+}
+
 func (l *Local) iShouldHaveOtherResponsesWithStatus(statusOrCode string) error {
 	code, err := statusCode(statusOrCode)
 	if err != nil {
 		return err
 	}
 
-	return l.ExpectOtherResponsesStatus(code)
+	return l.poll(func() error {
+		return l.expectOtherResponses(func(ex exchange) error {
+			return checkStatus(code, ex)
+		})
+	})
 }
 
 func (l *Local) iShouldHaveResponseWithStatus(statusOrCode string) error {
@@ -293,57 +740,133 @@ func (l *Local) iShouldHaveResponseWithStatus(statusOrCode string) error {
 		return err
 	}
 
-	return l.ExpectResponseStatus(code)
+	return l.poll(func() error {
+		return l.expectResponse(func(ex exchange) error {
+			return checkStatus(code, ex)
+		})
+	})
 }
 
 func (l *Local) iShouldHaveOtherResponsesWithHeader(key, value string) error {
-	return l.ExpectOtherResponsesHeader(key, value)
+	return l.poll(func() error {
+		return l.expectOtherResponses(func(ex exchange) error {
+			return l.checkHeader(key, value, ex)
+		})
+	})
 }
 
 func (l *Local) iShouldHaveResponseWithHeader(key, value string) error {
-	return l.ExpectResponseHeader(key, value)
+	return l.poll(func() error {
+		return l.expectResponse(func(ex exchange) error {
+			return l.checkHeader(key, value, ex)
+		})
+	})
+}
+
+func (l *Local) iShouldHaveResponseWithHeaderCapturedAs(key, varName string) error {
+	return l.poll(func() error {
+		resp, err := l.response()
+		if err != nil {
+			return err
+		}
+
+		values, found := resp.resp.Header[http.CanonicalHeaderKey(key)]
+		if !found {
+			return fmt.Errorf("%w: %s", errMissingHeader, key)
+		}
+
+		return l.capture(varName, values[0])
+	})
+}
+
+func (l *Local) iShouldHaveResponseWithCookieCapturedAs(name, varName string) error {
+	return l.poll(func() error {
+		resp, err := l.response()
+		if err != nil {
+			return err
+		}
+
+		for _, c := range resp.resp.Cookies() {
+			if c.Name == name {
+				return l.capture(varName, c.Value)
+			}
+		}
+
+		return fmt.Errorf("%w: %s", errMissingCookie, name)
+	})
+}
+
+// capture sets variable value or checks it if variable is already defined.
+func (l *Local) capture(varName, value string) error {
+	vars := l.JSONComparer.Vars
+	if vars == nil || !vars.IsVar(varName) {
+		return fmt.Errorf("%w: %q", errInvalidVariable, varName)
+	}
+
+	if v, found := vars.Get(varName); found {
+		// Values captured from JSON can be numbers.
+		if str, err := varString(v); err != nil || str != value {
+			return fmt.Errorf("%w %s, expected: %v, received: %s", errVariableMismatch, varName, v, value)
+		}
+
+		return nil
+	}
+
+	vars.Set(varName, value)
+
+	return nil
 }
 
 func (l *Local) iShouldHaveResponseWithBody(bodyDoc *godog.DocString) error {
-	body, err := loadBody([]byte(bodyDoc.Content), l.JSONComparer.Vars)
+	body, err := loadGeneratedBody([]byte(bodyDoc.Content), l.JSONComparer.Vars)
 	if err != nil {
 		return err
 	}
 
-	return l.ExpectResponseBody(body)
+	return l.poll(func() error {
+		return l.expectResponse(func(ex exchange) error {
+			return l.checkBody(body, ex)
+		})
+	})
 }
 
 func (l *Local) iShouldHaveResponseWithBodyFromFile(filePath *godog.DocString) error {
-	body, err := loadBodyFromFile(filePath.Content, l.JSONComparer.Vars)
+	body, err := loadGeneratedBodyFromFile(filePath.Content, l.JSONComparer.Vars)
 	if err != nil {
 		return err
 	}
 
-	return l.ExpectResponseBody(body)
+	return l.poll(func() error {
+		return l.expectResponse(func(ex exchange) error {
+			return l.checkBody(body, ex)
+		})
+	})
 }
 
 func (l *Local) iShouldHaveOtherResponsesWithBody(bodyDoc *godog.DocString) error {
-	body, err := loadBody([]byte(bodyDoc.Content), l.JSONComparer.Vars)
+	body, err := loadGeneratedBody([]byte(bodyDoc.Content), l.JSONComparer.Vars)
 	if err != nil {
 		return err
 	}
 
-	return l.ExpectOtherResponsesBody(body)
+	return l.poll(func() error {
+		return l.expectOtherResponses(func(ex exchange) error {
+			return l.checkBody(body, ex)
+		})
+	})
 }
 
 func (l *Local) iShouldHaveOtherResponsesWithBodyFromFile(filePath *godog.DocString) error {
-	body, err := loadBodyFromFile(filePath.Content, l.JSONComparer.Vars)
+	body, err := loadGeneratedBodyFromFile(filePath.Content, l.JSONComparer.Vars)
 	if err != nil {
 		return err
 	}
 
-	return l.ExpectOtherResponsesBody(body)
-}
-
-func (l *Local) iRequestWithConcurrency() error {
-	l.Concurrently()
-
-	return nil
+	return l.poll(func() error {
+		return l.expectOtherResponses(func(ex exchange) error {
+			return l.checkBody(body, ex)
+		})
+	})
 }
 
 var statusMap = map[string]int{}
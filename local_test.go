@@ -2,14 +2,28 @@ package httpdog_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/bool64/httpdog"
 	"github.com/cucumber/godog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/swaggest/rest/resttest"
 )
 
@@ -190,6 +204,57 @@ func TestLocal_RegisterSteps_dynamic(t *testing.T) {
 			return
 		}
 
+		if r.URL.Path == "/echo" {
+			w.Header().Set("X-Request-Id", r.Header.Get("X-Request-Id"))
+
+			_, err := io.Copy(w, r.Body)
+			assert.NoError(t, err)
+
+			return
+		}
+
+		if r.URL.Path == "/search" {
+			assert.Equal(t, "10", r.URL.Query().Get("$top"))
+			assert.Equal(t, "rock & roll+", r.URL.Query().Get("q"))
+			assert.Equal(t, "$query", r.URL.Query().Get("literal"))
+			assert.Equal(t, "$filter eq rock & roll+", r.Header.Get("X-Filter"))
+
+			return
+		}
+
+		if r.URL.Path == "/session" {
+			w.Header().Set("Location", "/sessions/abc123")
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123"})
+			w.WriteHeader(http.StatusCreated)
+
+			return
+		}
+
+		if r.URL.Path == "/sessions/abc123" {
+			c, err := r.Cookie("session")
+			assert.NoError(t, err)
+			assert.Equal(t, "abc123", c.Value)
+
+			w.Header().Set("X-Session", "abc123")
+			w.Header().Set("X-User-Id", "12345")
+
+			_, err = w.Write([]byte(`{"user_id":12345}`))
+			assert.NoError(t, err)
+
+			return
+		}
+
+		if r.URL.Path == "/orders" {
+			assert.Equal(t, "12345", r.URL.Query().Get("user_id"))
+			assert.Equal(t, "12345", r.Header.Get("X-User-Id"))
+
+			c, err := r.Cookie("user")
+			assert.NoError(t, err)
+			assert.Equal(t, "12345", c.Value)
+
+			return
+		}
+
 		if r.URL.Path == "/order" {
 			b, err := ioutil.ReadAll(r.Body)
 			assert.NoError(t, err)
@@ -205,6 +270,12 @@ func TestLocal_RegisterSteps_dynamic(t *testing.T) {
 	}))
 	defer srv.Close()
 
+	require.NoError(t, os.Setenv("HTTPDOG_TEST_ENV", "foo"))
+
+	defer func() {
+		require.NoError(t, os.Unsetenv("HTTPDOG_TEST_ENV"))
+	}()
+
 	local := httpdog.NewLocal(srv.URL)
 
 	suite := godog.TestSuite{
@@ -222,3 +293,598 @@ func TestLocal_RegisterSteps_dynamic(t *testing.T) {
 		t.Fatal("test failed")
 	}
 }
+
+func TestLocal_RegisterSteps_responseFail(t *testing.T) {
+	// Multibyte rune crosses the limit of failure body.
+	long := strings.Repeat("a", 999) + strings.Repeat("é", 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := `{"id":12345,"name":"John Doe"}`
+		if r.URL.Path == "/long" {
+			body = long
+		}
+
+		_, err := w.Write([]byte(body))
+		assert.NoError(t, err)
+	}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL)
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Output:   out,
+			Format:   "pretty",
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/LocalFail2.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "unexpected value at JSON path $.id, expected: 54321, received: 12345,\n")
+	assert.Contains(t, out.String(), "failed to evaluate JSON path $.foo: unknown key foo")
+	assert.Contains(t, out.String(), "response body does not match JSON schema:\n")
+	assert.Contains(t, out.String(), "/: missing properties: 'email'\n")
+	assert.Contains(t, out.String(), "/id: expected string, but got number\n")
+	assert.Contains(t, out.String(), "/name: length must be <= 3, but got 8\n")
+	assert.Contains(t, out.String(), "unknown variable: $unknown\n")
+	assert.Contains(t, out.String(), "missing response header: X-Missing\n")
+	assert.Contains(t, out.String(), "unexpected value of variable $name, expected: John Doe, received: text/plain; charset=utf-8\n")
+	assert.Contains(t, out.String(), "failed to generate $env(HTTPDOG_MISSING_ENV): environment variable is not set: HTTPDOG_MISSING_ENV\n")
+	assert.Regexp(t, `unexpected response status, expected: 201 \(Created\), received: 200 \(OK\)\n\s*request:\n\s*`+
+		`curl -X POST '`+regexp.QuoteMeta(srv.URL)+`/user\?name=(\w{4})' -H 'Authorization: Bearer secret' `+
+		`-H 'Cookie: session=secret' -H 'X-Name: (\w{4})' -H 'X-Note: it'\\''s me' `+
+		`--data-raw '\{"name":"(\w{4})"\}'\n\s*`+
+		`response:\n\s*HTTP/1.1 200 OK\n\s*Content-Length: 30\n\s*Content-Type: text/plain; charset=utf-8\n\s*Date: .+\n\s*\n\s*`+
+		`\{"id":12345,"name":"John Doe"\}`, out.String())
+	assert.Contains(t, out.String(), "\n"+strings.Repeat("a", 999)+"...")
+}
+
+func TestLocal_RegisterSteps_maskCredentials(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL, func(l *httpdog.Local) {
+		l.MaskCredentials = true
+	})
+
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Output:   out,
+			Format:   "pretty",
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/LocalFail2.feature"},
+			Tags:     "@reproducible",
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "-H 'Authorization: Bearer ***' -H 'Cookie: session=***' ")
+	assert.NotContains(t, out.String(), "Bearer secret'")
+	assert.NotContains(t, out.String(), "session=secret")
+
+	// Transport sets default User-Agent, it is not a part of request configured by steps.
+	assert.NotContains(t, out.String(), "User-Agent")
+}
+
+func TestLocal_RegisterSteps_openAPI(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.Method + " " + r.URL.RequestURI() {
+		case "GET /api/users/1":
+			_, _ = w.Write([]byte(`{"id":1,"name":"John","email":null}`))
+		case "GET /api/users/2":
+			_, _ = w.Write([]byte(`{"id":"2","name":"Jane"}`))
+		case "GET /api/users/abc":
+			w.WriteHeader(http.StatusTeapot)
+		case "GET /api/users?limit=500":
+			_, _ = w.Write([]byte(`[]`))
+		case "POST /api/users":
+			w.Header().Set("Location", "/users/2")
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	spec, err := httpdog.LoadOpenAPIFile("_testdata/openapi.yaml")
+	require.NoError(t, err)
+
+	local := httpdog.NewLocal(srv.URL+"/api", func(l *httpdog.Local) {
+		l.OpenAPI = spec
+	})
+
+	report := bytes.NewBuffer(nil)
+	spec.Output = report
+
+	suite := godog.TestSuite{
+		TestSuiteInitializer: spec.RegisterSuite,
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Format: "pretty",
+			Strict: true,
+			Paths:  []string{"_testdata/OpenAPI.feature"},
+		},
+	}
+
+	if suite.Run() != 0 {
+		t.Fatal("test failed")
+	}
+
+	assert.Equal(t, []string{"GET /users"}, spec.Uncovered())
+	assert.Equal(t, "OpenAPI coverage: 2 of 3 operations covered\n"+
+		"Operations not covered by any scenario:\n  GET /users\n", report.String())
+
+	out := bytes.NewBuffer(nil)
+	suite.Options = &godog.Options{
+		Output:   out,
+		Format:   "pretty",
+		NoColors: true,
+		Strict:   true,
+		Paths:    []string{"_testdata/OpenAPIFail.feature"},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "OpenAPI contract violation for GET /api/users/2:\n"+
+		"response body /id: Field must be set to integer or not be present\n")
+	assert.Contains(t, out.String(), "OpenAPI contract violation for GET /api/users/abc:\n"+
+		"path parameter \"id\": value abc: an invalid integer: strconv.ParseFloat: parsing \"abc\": invalid syntax\n"+
+		"response: status is not supported\n")
+	assert.Contains(t, out.String(), "OpenAPI contract violation for GET /api/users?limit=500:\n"+
+		"query parameter \"limit\": number must be at most 100\n")
+	assert.Contains(t, out.String(), "OpenAPI contract violation: operation is not defined in OpenAPI document: "+
+		"method DELETE is not allowed for /api/users/1\n")
+	assert.Contains(t, out.String(), "unexpected response status, expected: 404 (Not Found), received: 200 (OK)\n"+
+		"OpenAPI contract violation for GET /api/users/2:\n"+
+		"response body /id: Field must be set to integer or not be present\n")
+
+	assert.Empty(t, spec.Uncovered())
+
+	report.Reset()
+	require.NoError(t, spec.WriteCoverage(report))
+	assert.Equal(t, "OpenAPI coverage: 3 of 3 operations covered\n", report.String())
+}
+
+func TestLocal_RegisterSteps_polling(t *testing.T) {
+	var jobCalls, progressCalls int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "abc", r.Header.Get("X-Job"))
+
+		// Job is done on third request.
+		if r.URL.Path == "/job" && atomic.AddInt64(&jobCalls, 1) >= 3 {
+			_, err := w.Write([]byte(`{"status":"done"}`))
+			assert.NoError(t, err)
+
+			return
+		}
+
+		// Progress is done on third request, every response has new update time.
+		if r.URL.Path == "/progress" {
+			calls := atomic.AddInt64(&progressCalls, 1)
+			status := "pending"
+
+			if calls >= 3 {
+				status = "done"
+			}
+
+			_, err := w.Write([]byte(fmt.Sprintf(`{"status":%q,"updated_at":%d}`, status, calls)))
+			assert.NoError(t, err)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+
+		_, err := w.Write([]byte(`{"status":"pending"}`))
+		assert.NoError(t, err)
+	}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL)
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Output:   out,
+			Format:   "pretty",
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/Polling.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Equal(t, int64(3), atomic.LoadInt64(&jobCalls))
+	assert.Equal(t, int64(3), atomic.LoadInt64(&progressCalls))
+	assert.Contains(t, out.String(), "3 scenarios (2 passed, 1 failed)")
+	assert.Contains(t, out.String(), "response does not match within 200ms after ")
+	assert.Contains(t, out.String(), " attempts, last attempt: not equal:\n {\n-  \"status\": \"done\"\n+  \"status\": \"pending\"\n }")
+}
+
+func TestLocal_RegisterSteps_latency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL)
+	local.ConcurrencyLevel = 5
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Output:   out,
+			Format:   "pretty",
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/Latency.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Contains(t, out.String(), "4 scenarios (2 passed, 2 failed)")
+	assert.Regexp(t, `response is too slow: [\d.]+ms, expected within 10ms\n`, out.String())
+	assert.Regexp(t, `other responses are too slow: p90 [\d.]+ms of 4 responses, expected within 10ms, `+
+		`durations: ([\d.]+ms, ){3}[\d.]+ms\n`, out.String())
+}
+
+func TestLocal_RegisterSteps_gzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			_, _ = w.Write([]byte(`{"id":12345}`))
+
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+
+		zw := gzip.NewWriter(w)
+		_, _ = zw.Write([]byte(`{"id":12345}`))
+		assert.NoError(t, zw.Close())
+	}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL)
+
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Output:   out,
+			Format:   "pretty",
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/Gzip.feature"},
+		},
+	}
+
+	assert.Equal(t, 0, suite.Run(), out.String())
+}
+
+func TestLocal_RegisterSteps_concurrency(t *testing.T) {
+	var created, flaky, gets int64
+
+	// Multibyte rune crosses the limit of sample body.
+	long := strings.Repeat("a", 199) + strings.Repeat("é", 10)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/long":
+			_, err := w.Write([]byte(long))
+			assert.NoError(t, err)
+		case r.URL.Path == "/flaky":
+			switch atomic.AddInt64(&flaky, 1) % 3 {
+			case 0:
+				w.WriteHeader(http.StatusInternalServerError)
+			case 1:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case r.Method == http.MethodPost:
+			if atomic.AddInt64(&created, 1) == 1 {
+				w.WriteHeader(http.StatusCreated)
+			} else {
+				w.WriteHeader(http.StatusConflict)
+			}
+		default:
+			atomic.AddInt64(&gets, 1)
+
+			_, err := w.Write([]byte(`{"status":"pending"}`))
+			assert.NoError(t, err)
+		}
+	}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL)
+	out := bytes.NewBuffer(nil)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Output:   out,
+			Format:   "pretty",
+			NoColors: true,
+			Strict:   true,
+			Paths:    []string{"_testdata/Concurrency.feature"},
+		},
+	}
+
+	assert.Equal(t, 1, suite.Run())
+	assert.Equal(t, int64(20), atomic.LoadInt64(&created))
+	assert.Equal(t, int64(8), atomic.LoadInt64(&gets))
+	assert.Contains(t, out.String(), "6 scenarios (2 passed, 4 failed)")
+	assert.Contains(t, out.String(), "invalid number of concurrent requests: 1, at least 2 expected")
+	assert.Contains(t, out.String(), "distribution of 2 responses:\n"+
+		"2 with status 200 (OK), body: "+strings.Repeat("a", 199)+"...\n")
+	assert.Contains(t, out.String(), "distribution of 6 responses:\n"+
+		"2 with status 200 (OK), no body\n"+
+		"2 with status 404 (Not Found), no body\n"+
+		"2 with status 500 (Internal Server Error), no body\n")
+	assert.Contains(t, out.String(), "distribution of 3 responses:\n"+
+		"3 with status 200 (OK), body: {\"status\":\"pending\"}\n")
+}
+
+func TestNewLocals(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/orders", r.URL.Path)
+		assert.Equal(t, "gateway", r.Header.Get("X-Service"))
+
+		w.WriteHeader(http.StatusCreated)
+
+		_, err := w.Write([]byte(`{"order_id":123,"invoice_id":"inv-456"}`))
+		assert.NoError(t, err)
+	}))
+	defer gateway.Close()
+
+	billing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/invoices/inv-456", r.URL.Path)
+		assert.Empty(t, r.Header.Get("X-Service"))
+
+		_, err := w.Write([]byte(`{"id":"inv-456","order_id":123}`))
+		assert.NoError(t, err)
+	}))
+	defer billing.Close()
+
+	locals := httpdog.NewLocals(map[string]string{
+		"gateway": gateway.URL,
+		"billing": billing.URL,
+	})
+
+	assert.NotNil(t, locals.Get("billing"))
+	assert.Nil(t, locals.Get("unknown"))
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			locals.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Format: "pretty",
+			Strict: true,
+			Paths:  []string{"_testdata/Locals.feature"},
+		},
+	}
+
+	if suite.Run() != 0 {
+		t.Fatal("test failed")
+	}
+
+	order, found := locals.Vars.Get("$order_id")
+	assert.True(t, found)
+	assert.Equal(t, int64(123), order)
+}
+
+func TestLocal_RegisterSteps_tables(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"red & blue", "green"}, r.URL.Query()["tag"])
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		form, err := url.ParseQuery(string(body))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"John Doe", "Jane"}, form["name"])
+
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]string{
+			"uri":         r.RequestURI,
+			"body":        string(body),
+			"contentType": r.Header.Get("Content-Type"),
+			"foo":         r.Header.Get("X-Foo"),
+			"query":       r.Header.Get("X-Query"),
+		}))
+	}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+
+			s.Step(`^variable "([^"]*)" is "([^"]*)"$`, func(name, value string) {
+				local.JSONComparer.Vars.Set(name, value)
+			})
+		},
+		Options: &godog.Options{
+			Format: "pretty",
+			Strict: true,
+			Paths:  []string{"_testdata/Tables.feature"},
+		},
+	}
+
+	if suite.Run() != 0 {
+		t.Fatal("test failed")
+	}
+}
+
+func TestLocal_RegisterSteps_multipart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		require.NoError(t, err)
+
+		type part struct {
+			Name        string `json:"name"`
+			FileName    string `json:"fileName,omitempty"`
+			ContentType string `json:"contentType"`
+			Data        string `json:"data"`
+		}
+
+		var parts []part
+
+		for {
+			p, err := mr.NextPart()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+
+			require.NoError(t, err)
+
+			data, err := ioutil.ReadAll(p)
+			require.NoError(t, err)
+
+			parts = append(parts, part{
+				Name:        p.FormName(),
+				FileName:    p.FileName(),
+				ContentType: p.Header.Get("Content-Type"),
+				Data:        strings.TrimSpace(string(data)),
+			})
+		}
+
+		assert.NoError(t, json.NewEncoder(w).Encode(parts))
+	}))
+	defer srv.Close()
+
+	local := httpdog.NewLocal(srv.URL)
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+
+			s.Before(func(ctx context.Context, _ *godog.Scenario) (context.Context, error) {
+				local.JSONComparer.Vars.Set("$report_id", 42)
+				local.JSONComparer.Vars.Set("$editor", "@jane")
+
+				return ctx, nil
+			})
+		},
+		Options: &godog.Options{
+			Format: "pretty",
+			Strict: true,
+			Paths:  []string{"_testdata/Multipart.feature"},
+		},
+	}
+
+	if suite.Run() != 0 {
+		t.Fatal("test failed")
+	}
+}
+
+func TestLocal_HAR(t *testing.T) {
+	es := httpdog.External{}
+	userServiceURL := es.Add("user-service")
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := http.Get(userServiceURL + "/users/" + r.URL.Query().Get("id"))
+		require.NoError(t, err)
+
+		user, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"profile":` + string(user) + `}`))
+	}))
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "har")
+	require.NoError(t, err)
+
+	defer func() {
+		require.NoError(t, os.RemoveAll(dir))
+	}()
+
+	har := &httpdog.HAR{Dir: dir}
+	es.HAR = har
+
+	local := httpdog.NewLocal(srv.URL, func(l *httpdog.Local) {
+		l.HAR = har
+	})
+
+	suite := godog.TestSuite{
+		ScenarioInitializer: func(s *godog.ScenarioContext) {
+			local.RegisterSteps(s)
+			es.RegisterSteps(s)
+		},
+		Options: &godog.Options{
+			Format: "pretty",
+			Strict: true,
+			Paths:  []string{"_testdata/HAR.feature"},
+		},
+	}
+
+	if suite.Run() != 0 {
+		t.Fatal("test failed")
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "har", "profile-is-composed-of-user.har"))
+	require.NoError(t, err)
+
+	var l struct {
+		Log struct {
+			Version string `json:"version"`
+			Entries []struct {
+				Request struct {
+					Method string `json:"method"`
+					URL    string `json:"url"`
+				} `json:"request"`
+				Response struct {
+					Status  int `json:"status"`
+					Content struct {
+						Text string `json:"text"`
+					} `json:"content"`
+				} `json:"response"`
+				Comment string `json:"comment"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+
+	require.NoError(t, json.Unmarshal(data, &l))
+	assert.Equal(t, "1.2", l.Log.Version)
+	require.Len(t, l.Log.Entries, 2)
+
+	assert.Equal(t, srv.URL+"/profile?id=1", l.Log.Entries[0].Request.URL)
+	assert.Equal(t, `{"profile":{"name":"John"}}`, l.Log.Entries[0].Response.Content.Text)
+	assert.Equal(t, "", l.Log.Entries[0].Comment)
+
+	assert.Equal(t, userServiceURL+"/users/1", l.Log.Entries[1].Request.URL)
+	assert.Equal(t, 200, l.Log.Entries[1].Response.Status)
+	assert.Equal(t, "user-service", l.Log.Entries[1].Comment)
+}
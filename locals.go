@@ -0,0 +1,84 @@
+package httpdog
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/bool64/shared"
+	"github.com/cucumber/godog"
+)
+
+// NewLocals creates a collection of named local services by their base URLs.
+//
+// Options are applied to every service.
+func NewLocals(baseURLs map[string]string, options ...func(l *Local)) *Locals {
+	ls := Locals{
+		Vars:     &shared.Vars{},
+		services: make(map[string]*Local, len(baseURLs)),
+	}
+
+	for service, baseURL := range baseURLs {
+		ls.services[service] = NewLocal(baseURL, options...)
+	}
+
+	ls.shareVars()
+
+	return &ls
+}
+
+// Locals is a collection of step-driven HTTP clients for named application services.
+//
+// Every service keeps its own client state, variables are shared.
+type Locals struct {
+	Vars *shared.Vars
+
+	services map[string]*Local
+}
+
+// Get returns client of a named service.
+func (ls *Locals) Get(service string) *Local {
+	return ls.services[service]
+}
+
+// shareVars makes all services use common variables.
+func (ls *Locals) shareVars() {
+	for _, l := range ls.services {
+		l.JSONComparer.Vars = ls.Vars
+	}
+}
+
+// RegisterSteps adds HTTP client steps for named services to godog scenario context.
+//
+// Steps are the same as of Local, with quoted service name before HTTP endpoint and response.
+//
+//		When I request "billing" HTTP endpoint with method "GET" and URI "/invoices/$invoice_id"
+//		And I request "billing" HTTP endpoint with header "X-Foo: bar"
+//
+//		Then I should have "billing" response with status "OK"
+//		And I should have "billing" response with body
+//		"""
+//		{"id":"$invoice_id"}
+//		"""
+//
+// Other responses of idempotent mode are referred with service name too.
+//
+//		And I concurrently request idempotent "billing" HTTP endpoint
+//		And I should have other "billing" responses with status "Conflict"
+func (ls *Locals) RegisterSteps(s *godog.ScenarioContext) {
+	ls.shareVars()
+
+	services := make([]string, 0, len(ls.services))
+	for service := range ls.services {
+		services = append(services, service)
+	}
+
+	sort.Strings(services)
+
+	for _, service := range services {
+		l := ls.services[service]
+		name := `"` + regexp.QuoteMeta(service) + `"`
+
+		l.hooks(s, service)
+		l.steps(s, name+" HTTP endpoint", name+" response", "other "+name+" responses")
+	}
+}
@@ -0,0 +1,302 @@
+package httpdog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+
+	"github.com/bool64/shared"
+	"github.com/swaggest/assertjson"
+	"github.com/swaggest/assertjson/json5"
+	"github.com/swaggest/rest/resttest"
+)
+
+// expectationHeader routes prepared request to selected expectation of mock.
+const expectationHeader = "X-Httpdog-Expectation"
+
+// registered is an expectation queued in mock of a service with properties that mock does not check itself.
+type registered struct {
+	exp
+	uri       uriMatcher
+	remaining int
+
+	// id is a value of expectationHeader that mock requires for expectation.
+	id string
+}
+
+// usedUp tells if mock has already discarded expectation.
+func (r *registered) usedUp() bool {
+	return !r.Unlimited && r.remaining <= 0
+}
+
+// resetExpected discards registered expectations of previous scenario.
+//
+// Mock does not reset async expectations, but they are not used anymore as requests are not routed to them.
+func (e *External) resetExpected() {
+	e.serveMu.Lock()
+	defer e.serveMu.Unlock()
+
+	e.expected = nil
+}
+
+// rank orders async expectations, exact URIs are preferred over patterns and partial queries.
+func (r *registered) rank() int {
+	switch {
+	case r.uri.partial:
+		return 2
+	case r.uri.pattern != nil:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// body returns request body as expectation checks it, form data is converted to JSON.
+func (r *registered) body(req *http.Request, body []byte) []byte {
+	if r.form {
+		return formToJSON(req.Header.Get("Content-Type"), body)
+	}
+
+	return body
+}
+
+// prepare makes a copy of request for mock with request URI and header of expectation and form data as JSON.
+func (r *registered) prepare(req *http.Request, body []byte) *http.Request {
+	body = r.body(req, body)
+
+	p := req.Clone(req.Context())
+	p.RequestURI = r.RequestURI
+	p.Header.Set(expectationHeader, r.id)
+	p.Body = ioutil.NopCloser(bytes.NewReader(body))
+	p.ContentLength = int64(len(body))
+
+	return p
+}
+
+// accepts tells if expectation accepts request in the same way as mock checks it, request URI is matched separately.
+func (r *registered) accepts(req *http.Request, body []byte, comparer assertjson.Comparer) bool {
+	if r.Method != "" && r.Method != req.Method {
+		return false
+	}
+
+	for k, v := range r.RequestHeader {
+		if req.Header.Get(k) != v {
+			return false
+		}
+	}
+
+	for n, v := range r.RequestCookie {
+		if c, err := req.Cookie(n); err != nil || c.Value != v {
+			return false
+		}
+	}
+
+	if r.RequestBody == nil {
+		return true
+	}
+
+	body = r.body(req, body)
+
+	if !json5.Valid(r.RequestBody) || !json5.Valid(body) {
+		return bytes.Equal(r.RequestBody, body)
+	}
+
+	expected, err := json5.Downgrade(r.RequestBody)
+	if err != nil {
+		return false
+	}
+
+	return comparer.FailNotEqual(expected, body) == nil
+}
+
+// expect queues expectation in mock of a service and registers it to prepare received requests.
+//
+// Expectation in mock requires unique expectationHeader, so that mock uses the selected one.
+func (e *External) expect(service string, pending exp, uri uriMatcher) {
+	r := &registered{exp: pending, uri: uri, remaining: pending.Repeated}
+	if r.remaining == 0 {
+		r.remaining = 1
+	}
+
+	e.serveMu.Lock()
+	defer e.serveMu.Unlock()
+
+	e.seq++
+	r.id = strconv.Itoa(e.seq)
+
+	if e.expected == nil {
+		e.expected = make(map[string][]*registered, 1)
+	}
+
+	e.expected[service] = append(e.expected[service], r)
+
+	m := r.Expectation
+	m.RequestHeader = make(map[string]string, len(r.RequestHeader)+1)
+
+	for k, v := range r.RequestHeader {
+		m.RequestHeader[k] = v
+	}
+
+	m.RequestHeader[expectationHeader] = r.id
+
+	if r.async {
+		e.mocks[service].ExpectAsync(m)
+	} else {
+		e.mocks[service].Expect(m)
+	}
+}
+
+// inState tells if expectation can be used in current state of service.
+func (r *registered) inState(state string) bool {
+	return !r.conditional || r.state == state
+}
+
+// candidates lists expectations that mock can use for a request in order of preference
+// and returns first sequential expectation.
+//
+// Mock checks async expectations first, sequential expectation is only used if it is the first one.
+// Conditional expectations are skipped if service is in another state.
+func (e *External) candidates(service, state string) ([]*registered, *registered) {
+	var (
+		list []*registered
+		next *registered
+	)
+
+	for _, r := range e.expected[service] {
+		if r.usedUp() {
+			continue
+		}
+
+		if r.async {
+			if r.inState(state) {
+				list = append(list, r)
+			}
+		} else if next == nil {
+			next = r
+		}
+	}
+
+	sort.SliceStable(list, func(i, j int) bool { return list[i].rank() < list[j].rank() })
+
+	if next != nil && next.inState(state) {
+		list = append(list, next)
+	}
+
+	return list, next
+}
+
+// serve passes request to mock of a service, request is prepared for the expectation that accepts it.
+//
+// Accepted expectation is returned with recorded response, variables are captured from request URI
+// and state of service is changed only when expectation is accepted. If there is no such expectation,
+// request is prepared for first sequential expectation (if request URI matches) so that mock reports mismatch.
+func (e *External) serve(service string, mock *resttest.ServerMock, req *http.Request,
+	body []byte) (*registered, *httptest.ResponseRecorder) {
+	e.serveMu.Lock()
+	defer e.serveMu.Unlock()
+
+	rec := httptest.NewRecorder()
+
+	// Mock uses current Vars of External unless options of mock set Vars.
+	if mock.JSONComparer.Vars == nil {
+		mock.JSONComparer.Vars = e.Vars
+
+		defer func() { mock.JSONComparer.Vars = nil }()
+	}
+
+	state := e.state(service)
+
+	candidates, next := e.candidates(service, state)
+
+	for _, r := range candidates {
+		captured, ok := r.uri.match(req.RequestURI, e.Vars)
+		if !ok || !r.accepts(req, body, comparer(mock, captured)) {
+			continue
+		}
+
+		for name, value := range captured {
+			e.Vars.Set(name, value)
+		}
+
+		mock.ServeHTTP(rec, r.prepare(req, body))
+
+		r.remaining--
+
+		if r.behavior != nil && r.behavior.transition {
+			e.setState(service, r.behavior.state)
+		}
+
+		return r, rec
+	}
+
+	// Mock does not check state, so mismatch is reported with hooks of mock.
+	if next != nil && !next.inState(state) {
+		fail(mock, rec, fmt.Errorf("%w: %q expected, %q received", errUnexpectedState, next.state, state))
+
+		return nil, rec
+	}
+
+	if next != nil {
+		p := next.prepare(req, body)
+
+		if _, ok := next.uri.match(req.RequestURI, e.Vars); !ok {
+			p.RequestURI = req.RequestURI
+		}
+
+		req = p
+	}
+
+	mock.ServeHTTP(rec, req)
+
+	return nil, rec
+}
+
+// fail reports mismatch of request in the same way as mock does.
+func fail(mock *resttest.ServerMock, rw http.ResponseWriter, err error) {
+	if mock.OnError != nil {
+		mock.OnError(err)
+	}
+
+	if mock.ErrorResponder != nil {
+		mock.ErrorResponder(rw, err)
+
+		return
+	}
+
+	rw.WriteHeader(http.StatusInternalServerError)
+	_, _ = rw.Write([]byte(err.Error())) // nolint:errcheck // Recorder does not fail.
+}
+
+// comparer returns JSON comparer of mock with captured variables, variables of mock are not changed.
+func comparer(mock *resttest.ServerMock, captured map[string]string) assertjson.Comparer {
+	c := mock.JSONComparer
+	c.Vars = cloneVars(c.Vars)
+
+	if c.Vars == nil && len(captured) > 0 {
+		c.Vars = &shared.Vars{}
+	}
+
+	for name, value := range captured {
+		c.Vars.Set(name, value)
+	}
+
+	return c
+}
+
+func cloneVars(vars *shared.Vars) *shared.Vars {
+	if vars == nil {
+		return nil
+	}
+
+	c := &shared.Vars{VarPrefix: vars.VarPrefix}
+
+	for k, v := range vars.GetAll() {
+		c.Set(k, v)
+	}
+
+	return c
+}
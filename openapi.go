@@ -0,0 +1,360 @@
+package httpdog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cucumber/godog"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+var (
+	errContractViolation = errors.New("OpenAPI contract violation")
+	errUnknownOperation  = errors.New("operation is not defined in OpenAPI document")
+)
+
+// OpenAPI is an OpenAPI 3 document to validate HTTP traffic against.
+type OpenAPI struct {
+	// Output receives coverage report after test suite, os.Stdout is used by default.
+	Output io.Writer
+
+	doc    *openapi3.T
+	router routers.Router
+
+	mu      sync.Mutex
+	covered map[string]bool
+}
+
+// LoadOpenAPIFile loads OpenAPI 3 document in JSON or YAML format from file.
+//
+// Relative references to other files are resolved from document location.
+func LoadOpenAPIFile(filePath string) (*OpenAPI, error) {
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	doc, err := loader.LoadFromFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI document: %w", err)
+	}
+
+	return newOpenAPI(loader, doc)
+}
+
+// LoadOpenAPI loads OpenAPI 3 document in JSON or YAML format.
+func LoadOpenAPI(data []byte) (*OpenAPI, error) {
+	loader := openapi3.NewLoader()
+
+	doc, err := loader.LoadFromData(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load OpenAPI document: %w", err)
+	}
+
+	return newOpenAPI(loader, doc)
+}
+
+// serverOrigin matches scheme and host of server URL.
+var serverOrigin = regexp.MustCompile(`^[^/]*//[^/]*`)
+
+func newOpenAPI(loader *openapi3.Loader, doc *openapi3.T) (*OpenAPI, error) {
+	if err := doc.Validate(loader.Context); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+
+	// Services are tested on any host, so operations are routed by path with or without base path of servers.
+	routed := *doc
+	routed.Servers = nil
+
+	for _, s := range doc.Servers {
+		if basePath := strings.TrimRight(serverOrigin.ReplaceAllString(s.URL, ""), "/"); basePath != "" {
+			routed.Servers = append(routed.Servers, &openapi3.Server{URL: basePath})
+		}
+	}
+
+	if len(routed.Servers) > 0 {
+		routed.Servers = append(routed.Servers, &openapi3.Server{URL: "/"})
+	}
+
+	router, err := gorillamux.NewRouter(&routed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to route OpenAPI document: %w", err)
+	}
+
+	return &OpenAPI{
+		doc:     doc,
+		router:  router,
+		covered: map[string]bool{},
+	}, nil
+}
+
+// operations returns all operations of document in "METHOD /path" format.
+func (o *OpenAPI) operations() []string {
+	var res []string
+
+	for path, item := range o.doc.Paths {
+		for method := range item.Operations() {
+			res = append(res, method+" "+path)
+		}
+	}
+
+	sort.Strings(res)
+
+	return res
+}
+
+// Uncovered returns operations that were not requested, in "METHOD /path" format.
+func (o *OpenAPI) Uncovered() []string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var res []string
+
+	for _, op := range o.operations() {
+		if !o.covered[op] {
+			res = append(res, op)
+		}
+	}
+
+	return res
+}
+
+// WriteCoverage writes report of operations coverage.
+func (o *OpenAPI) WriteCoverage(w io.Writer) error {
+	uncovered := o.Uncovered()
+	total := len(o.operations())
+
+	report := fmt.Sprintf("OpenAPI coverage: %d of %d operations covered\n", total-len(uncovered), total)
+
+	if len(uncovered) > 0 {
+		report += "Operations not covered by any scenario:\n  " + strings.Join(uncovered, "\n  ") + "\n"
+	}
+
+	_, err := io.WriteString(w, report)
+
+	return err
+}
+
+// RegisterSuite adds coverage report to godog test suite context, report is written to Output after suite.
+//
+//		godog.TestSuite{
+//			TestSuiteInitializer: spec.RegisterSuite,
+//			...
+//		}
+func (o *OpenAPI) RegisterSuite(s *godog.TestSuiteContext) {
+	s.AfterSuite(func() {
+		w := o.Output
+		if w == nil {
+			w = os.Stdout
+		}
+
+		_ = o.WriteCoverage(w) // nolint:errcheck // Suite is over, there is nothing to fail.
+	})
+}
+
+// route finds operation of request.
+func (o *OpenAPI) route(req *http.Request) (*routers.Route, map[string]string, error) {
+	route, pathParams, err := o.router.FindRoute(req)
+	if err == nil {
+		return route, pathParams, nil
+	}
+
+	if errors.Is(err, routers.ErrMethodNotAllowed) {
+		err = fmt.Errorf("%w: method %s is not allowed for %s", errUnknownOperation, req.Method, req.URL.Path)
+	} else {
+		err = fmt.Errorf("%w: %s %s", errUnknownOperation, req.Method, req.URL.Path)
+	}
+
+	return nil, nil, fmt.Errorf("%w: %s", errContractViolation, err.Error())
+}
+
+// checkExchange validates request and response against OpenAPI and marks operation as covered.
+func (o *OpenAPI) checkExchange(ex exchange) error {
+	req := ex.req.Clone(context.Background())
+	req.Body = ioutil.NopCloser(bytes.NewReader(ex.reqBody))
+
+	route, pathParams, err := o.route(req)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	o.covered[route.Method+" "+route.Path] = true
+	o.mu.Unlock()
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    validationOptions(),
+	}
+
+	v := violations("request", openapi3filter.ValidateRequest(context.Background(), input))
+	v = append(v, violations("response", openapi3filter.ValidateResponse(context.Background(),
+		&openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 ex.resp.StatusCode,
+			Header:                 ex.resp.Header,
+			Body:                   ioutil.NopCloser(bytes.NewReader(ex.respBody)),
+			Options:                input.Options,
+		}))...)
+
+	return contractError(ex.req.Method, ex.req.URL.RequestURI(), v)
+}
+
+// checkExpectation validates mocked request URI and response against OpenAPI.
+//
+// Only path and query parameters of request are checked as expectation does not define others,
+// path parameters are not checked for URI template as they are placeholders.
+// Operation is not marked as covered, coverage is only collected from Local exchanges.
+func (o *OpenAPI) checkExpectation(method, requestURI string, kind uriPattern, status int, header map[string]string, body []byte) error {
+	u, err := url.ParseRequestURI(requestURI)
+	if err != nil {
+		return err
+	}
+
+	req := &http.Request{Method: method, URL: u, Header: http.Header{}}
+
+	route, pathParams, err := o.route(req)
+	if err != nil {
+		return err
+	}
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    validationOptions(),
+	}
+
+	var v []string
+
+	for _, p := range parameters(route) {
+		if p.In == openapi3.ParameterInQuery || (p.In == openapi3.ParameterInPath && kind != templateURI) {
+			v = append(v, violations("request", openapi3filter.ValidateParameter(context.Background(), input, p))...)
+		}
+	}
+
+	h := make(http.Header, len(header))
+	for k, val := range header {
+		h.Set(k, val)
+	}
+
+	// Mocked JSON responses often omit content type.
+	if h.Get("Content-Type") == "" && json.Valid(body) {
+		h.Set("Content-Type", "application/json")
+	}
+
+	v = append(v, violations("response", openapi3filter.ValidateResponse(context.Background(),
+		&openapi3filter.ResponseValidationInput{
+			RequestValidationInput: input,
+			Status:                 status,
+			Header:                 h,
+			Body:                   ioutil.NopCloser(bytes.NewReader(body)),
+			Options:                input.Options,
+		}))...)
+
+	return contractError(method, requestURI, v)
+}
+
+// parameters returns parameters of operation, operation parameters override path item parameters.
+func parameters(route *routers.Route) []*openapi3.Parameter {
+	res := make([]*openapi3.Parameter, 0, len(route.Operation.Parameters)+len(route.PathItem.Parameters))
+
+	for _, p := range route.Operation.Parameters {
+		res = append(res, p.Value)
+	}
+
+	for _, p := range route.PathItem.Parameters {
+		if route.Operation.Parameters.GetByInAndName(p.Value.In, p.Value.Name) == nil {
+			res = append(res, p.Value)
+		}
+	}
+
+	return res
+}
+
+// validationOptions makes validator report all violations, security requirements are not checked.
+func validationOptions() *openapi3filter.Options {
+	return &openapi3filter.Options{
+		MultiError:            true,
+		IncludeResponseStatus: true,
+		AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+	}
+}
+
+// violations splits validation error into messages prefixed with subject of violation.
+func violations(subject string, err error) []string {
+	var (
+		me  openapi3.MultiError
+		se  *openapi3.SchemaError
+		req *openapi3filter.RequestError
+		res *openapi3filter.ResponseError
+	)
+
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &req):
+		return violations(requestSubject(req), req.Err)
+	case errors.As(err, &res):
+		if i := strings.Index(res.Reason, " doesn't match"); i > 0 {
+			return violations(res.Reason[:i], res.Err)
+		}
+
+		if res.Err == nil || !strings.HasPrefix(res.Reason, "response") {
+			return []string{subject + ": " + res.Error()}
+		}
+
+		return []string{res.Error()}
+	case errors.As(err, &me):
+		var v []string
+
+		for _, e := range me {
+			v = append(v, violations(subject, e)...)
+		}
+
+		return v
+	case errors.As(err, &se):
+		if ptr := se.JSONPointer(); len(ptr) > 0 {
+			return []string{subject + " /" + strings.Join(ptr, "/") + ": " + se.Reason}
+		}
+
+		return []string{subject + ": " + se.Reason}
+	default:
+		return []string{subject + ": " + err.Error()}
+	}
+}
+
+func requestSubject(err *openapi3filter.RequestError) string {
+	switch {
+	case err.Parameter != nil:
+		return err.Parameter.In + " parameter " + strconv.Quote(err.Parameter.Name)
+	case err.RequestBody != nil:
+		return "request body"
+	default:
+		return "request"
+	}
+}
+
+func contractError(method, requestURI string, violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w for %s %s:\n%s", errContractViolation, method, requestURI, strings.Join(violations, "\n"))
+}
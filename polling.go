@@ -0,0 +1,110 @@
+package httpdog
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bool64/shared"
+)
+
+var errNoMatch = errors.New("response does not match")
+
+// polling keeps state of repeating request until response matches expectations.
+type polling struct {
+	timeout  time.Duration
+	interval time.Duration
+	deadline time.Time
+	attempts int
+
+	// checks are expectations that were met by the current response.
+	checks []func() error
+}
+
+func (l *Local) iRequestUntilResponseMatches(timeout, interval string) error {
+	t, err := time.ParseDuration(timeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+
+	i, err := time.ParseDuration(interval)
+	if err != nil {
+		return fmt.Errorf("invalid interval: %w", err)
+	}
+
+	l.polling = &polling{
+		timeout:  t,
+		interval: i,
+		deadline: time.Now().Add(t),
+		attempts: 1,
+	}
+
+	return nil
+}
+
+// poll runs the check and repeats the request until all checks pass or timeout runs out.
+//
+// Expectations of previous steps are checked again with every new response.
+// Failure shows how to reproduce the last request.
+//
+// Variables captured by a failed attempt are discarded, so that next attempt compares or captures them anew.
+func (l *Local) poll(check func() error) error {
+	p := l.polling
+	if p == nil {
+		return l.reproducible(check())
+	}
+
+	err := l.attempt(check)
+
+	for err != nil && time.Now().Add(p.interval).Before(p.deadline) {
+		time.Sleep(p.interval)
+
+		l.resend()
+		p.attempts++
+
+		err = l.attempt(append(p.checks[:len(p.checks):len(p.checks)], check)...)
+	}
+
+	if err != nil {
+		return l.reproducible(
+			fmt.Errorf("%w within %s after %d attempts, last attempt: %v", errNoMatch, p.timeout, p.attempts, err))
+	}
+
+	p.checks = append(p.checks, check)
+
+	return nil
+}
+
+// attempt runs checks against current response and restores variables if any check fails.
+func (l *Local) attempt(checks ...func() error) error {
+	vars := l.JSONComparer.Vars
+	snapshot := cloneVars(vars)
+
+	for _, c := range checks {
+		if err := c(); err != nil {
+			restoreVars(vars, snapshot)
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restoreVars resets variables to the values of snapshot.
+func restoreVars(vars, snapshot *shared.Vars) {
+	if vars == nil {
+		return
+	}
+
+	vars.Reset()
+
+	for k, v := range snapshot.GetAll() {
+		vars.Set(k, v)
+	}
+}
+
+// resend discards received response, so that configured request is sent again by the checks.
+func (l *Local) resend() {
+	l.discard()
+}
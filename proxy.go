@@ -0,0 +1,281 @@
+package httpdog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cucumber/godog"
+)
+
+// Proxy records traffic of external service to replay it as mocked responses.
+//
+// Recordings are stored in a file per scenario (and per example of scenario outline) in a directory of feature,
+// requests of a scenario are replayed in recorded order.
+type Proxy struct {
+	// Record forwards requests to upstream and saves exchanges, recorded exchanges are replayed otherwise.
+	Record bool
+
+	// Dir is a directory for recordings, default _testdata/recordings.
+	Dir string
+
+	upstream string
+	service  string
+
+	mu        sync.Mutex
+	exchanges []recordedExchange
+}
+
+// recordedExchange is a request of external service with upstream response.
+//
+// Bodies that are not valid UTF-8 are base64 encoded.
+type recordedExchange struct {
+	Method               string      `json:"method"`
+	RequestURI           string      `json:"requestUri"`
+	RequestBody          string      `json:"requestBody,omitempty"`
+	RequestBodyEncoding  string      `json:"requestBodyEncoding,omitempty"`
+	Status               int         `json:"status"`
+	ResponseHeader       http.Header `json:"responseHeader,omitempty"`
+	ResponseBody         string      `json:"responseBody,omitempty"`
+	ResponseBodyEncoding string      `json:"responseBodyEncoding,omitempty"`
+}
+
+// encodeBody makes text of body, binary body is base64 encoded.
+func encodeBody(body []byte) (text string, encoding string) {
+	if utf8.Valid(body) {
+		return string(body), ""
+	}
+
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
+
+func decodeBody(text, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+
+	return []byte(text), nil
+}
+
+// skippedHeaders are not recorded as they are defined by transport.
+var skippedHeaders = map[string]bool{
+	"Connection":        true,
+	"Content-Length":    true,
+	"Date":              true,
+	"Keep-Alive":        true,
+	"Transfer-Encoding": true,
+}
+
+var errUpstreamFailed = errors.New("upstream request failed")
+
+// AddProxy starts a server for a named service that records or replays traffic of upstream and returns url.
+//
+// In record mode requests are forwarded to upstream, exchanges are saved after scenario.
+// In replay mode recorded exchanges are loaded as expectations before scenario,
+// other expectations can be added with steps.
+func (e *External) AddProxy(service, upstreamURL string, options ...func(p *Proxy)) string {
+	p := &Proxy{
+		Dir:      "_testdata/recordings",
+		upstream: strings.TrimSuffix(upstreamURL, "/"),
+		service:  service,
+	}
+
+	for _, option := range options {
+		option(p)
+	}
+
+	if e.proxies == nil {
+		e.proxies = make(map[string]*Proxy, 1)
+	}
+
+	e.proxies[service] = p
+
+	return e.Add(service)
+}
+
+// recording returns proxy of a service if it is in record mode.
+func (e *External) recording(service string) *Proxy {
+	if p := e.proxies[service]; p != nil && p.Record {
+		return p
+	}
+
+	return nil
+}
+
+var nonAlphaNum = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fileName makes file name of a scenario.
+//
+// Scenario name without latin letters and digits is replaced with its hash.
+func fileName(scenario string) string {
+	name := strings.Trim(nonAlphaNum.ReplaceAllString(strings.ToLower(scenario), "-"), "-")
+
+	if name == "" {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(scenario)) // nolint:errcheck // Hash does not fail.
+
+		name = fmt.Sprintf("scenario-%08x", h.Sum32())
+	}
+
+	return name
+}
+
+// file returns path to recording of a scenario.
+func (p *Proxy) file(sc *godog.Scenario) string {
+	return filepath.Join(p.Dir, p.service, scenarioFile(sc)+".json")
+}
+
+// forward sends request to upstream and records exchange.
+func (p *Proxy) forward(rw http.ResponseWriter, req *http.Request) {
+	reqBody, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+
+		return
+	}
+
+	ureq, err := http.NewRequest(req.Method, p.upstream+req.RequestURI, bytes.NewReader(reqBody))
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadGateway)
+
+		return
+	}
+
+	// Transport requests compression itself and decodes response, so that recording has plain body.
+	ureq.Header = req.Header.Clone()
+	ureq.Header.Del("Accept-Encoding")
+
+	resp, err := http.DefaultTransport.RoundTrip(ureq)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("%s: %s", errUpstreamFailed, err), http.StatusBadGateway)
+
+		return
+	}
+
+	defer resp.Body.Close() // nolint:errcheck // Body is read.
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(rw, fmt.Sprintf("%s: %s", errUpstreamFailed, err), http.StatusBadGateway)
+
+		return
+	}
+
+	ex := recordedExchange{
+		Method:     req.Method,
+		RequestURI: req.RequestURI,
+		Status:     resp.StatusCode,
+	}
+
+	ex.RequestBody, ex.RequestBodyEncoding = encodeBody(reqBody)
+	ex.ResponseBody, ex.ResponseBodyEncoding = encodeBody(respBody)
+
+	// Content-Encoding is kept if transport could not decode body, so that replayed body has same encoding.
+	for k, v := range resp.Header {
+		if skippedHeaders[k] {
+			continue
+		}
+
+		if ex.ResponseHeader == nil {
+			ex.ResponseHeader = make(http.Header)
+		}
+
+		ex.ResponseHeader[k] = v
+		rw.Header()[k] = v
+	}
+
+	p.mu.Lock()
+	p.exchanges = append(p.exchanges, ex)
+	p.mu.Unlock()
+
+	rw.WriteHeader(resp.StatusCode)
+	_, _ = rw.Write(respBody) // nolint:errcheck // Client may be gone.
+}
+
+// save writes recorded exchanges of a scenario, stale recording is removed if there were no exchanges.
+func (p *Proxy) save(sc *godog.Scenario) error {
+	p.mu.Lock()
+	exchanges := p.exchanges
+	p.exchanges = nil
+	p.mu.Unlock()
+
+	file := p.file(sc)
+
+	if len(exchanges) == 0 {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	data, err := json.MarshalIndent(exchanges, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, append(data, '\n'), 0o600)
+}
+
+// replay loads recorded exchanges of a scenario as expectations, missing recording means no requests.
+func (e *External) replay(p *Proxy, sc *godog.Scenario) error {
+	data, err := ioutil.ReadFile(p.file(sc))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+
+		return err
+	}
+
+	var exchanges []recordedExchange
+
+	if err := json.Unmarshal(data, &exchanges); err != nil {
+		return fmt.Errorf("failed to load recording of %s: %w", p.service, err)
+	}
+
+	for _, ex := range exchanges {
+		pending := exp{}
+		pending.Method = ex.Method
+		pending.RequestURI = ex.RequestURI
+		pending.Status = ex.Status
+		pending.ResponseHeader = map[string]string{}
+
+		// Response header may have multiple values.
+		pending.behavior = &behavior{header: ex.ResponseHeader}
+
+		if pending.ResponseBody, err = decodeBody(ex.ResponseBody, ex.ResponseBodyEncoding); err != nil {
+			return fmt.Errorf("failed to load recording of %s: %w", p.service, err)
+		}
+
+		if ex.RequestBody != "" {
+			if pending.RequestBody, err = decodeBody(ex.RequestBody, ex.RequestBodyEncoding); err != nil {
+				return fmt.Errorf("failed to load recording of %s: %w", p.service, err)
+			}
+		}
+
+		uri, err := newURIMatcher(ex.RequestURI, exactURI, false, e.Vars)
+		if err != nil {
+			return err
+		}
+
+		e.expect(p.service, pending, uri)
+	}
+
+	return nil
+}
@@ -0,0 +1,241 @@
+package httpdog
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// behavior defines how mocked response is delivered to client.
+type behavior struct {
+	delay time.Duration
+	hang  bool
+
+	// Faults of connection.
+	drop            bool
+	truncate        bool
+	truncateAfter   int
+	invalidLength   bool
+	malformedChunks bool
+
+	// State of service after response.
+	transition bool
+	state      string
+
+	// Response body is a template to render with received request.
+	template *template.Template
+
+	// Response header with multiple values, mock only sets single values.
+	header http.Header
+}
+
+// pendingBehavior returns behavior of pending expectation.
+func (e *External) pendingBehavior(service string) (*behavior, error) {
+	if _, ok := e.mocks[service]; !ok {
+		return nil, fmt.Errorf("%w: %q", errNoMockForService, service)
+	}
+
+	pending := e.pending[service]
+
+	if pending.Method == "" {
+		return nil, fmt.Errorf("%w: %q", errUndefinedRequest, service)
+	}
+
+	if pending.behavior == nil {
+		pending.behavior = &behavior{}
+		e.pending[service] = pending
+	}
+
+	return pending.behavior, nil
+}
+
+func (e *External) serviceRespondsAfter(service, delay string) error {
+	d, err := time.ParseDuration(delay)
+	if err != nil {
+		return fmt.Errorf("invalid delay: %w", err)
+	}
+
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.delay = d
+
+	return nil
+}
+
+func (e *External) serviceResponseHangs(service string) error {
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.hang = true
+
+	return nil
+}
+
+func (e *External) serviceDropsConnection(service string) error {
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.drop = true
+
+	return nil
+}
+
+func (e *External) serviceRespondsWithTruncatedBody(service string, n int) error {
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.truncate = true
+	b.truncateAfter = n
+
+	return nil
+}
+
+func (e *External) serviceRespondsWithInvalidContentLength(service string) error {
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.invalidLength = true
+
+	return nil
+}
+
+func (e *External) serviceRespondsWithMalformedChunkedBody(service string) error {
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.malformedChunks = true
+
+	return nil
+}
+
+// scenarioDone returns channel that is closed when scenario is finished.
+func (e *External) scenarioDone() <-chan struct{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.done
+}
+
+// addFailure keeps failure of response to report it after scenario.
+func (e *External) addFailure(failure string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.failures = append(e.failures, failure)
+}
+
+// respond sends recorded response of mock according to behavior of accepted expectation.
+//
+// Delayed or hanging response is aborted when client gives up or scenario is finished.
+func (e *External) respond(rw http.ResponseWriter, req *http.Request, b *behavior, rec *httptest.ResponseRecorder) {
+	if b != nil {
+		for k, v := range b.header {
+			rec.Header()[k] = v
+		}
+	}
+
+	if b != nil && (b.hang || b.delay > 0) {
+		var timer <-chan time.Time
+
+		if !b.hang {
+			t := time.NewTimer(b.delay)
+			defer t.Stop()
+
+			timer = t.C
+		}
+
+		select {
+		case <-timer:
+		case <-req.Context().Done():
+			panic(http.ErrAbortHandler)
+		case <-e.scenarioDone():
+			panic(http.ErrAbortHandler)
+		}
+	}
+
+	if b != nil && (b.drop || b.truncate || b.invalidLength || b.malformedChunks) {
+		b.fail(rw, rec)
+
+		return
+	}
+
+	for k, v := range rec.Header() {
+		rw.Header()[k] = v
+	}
+
+	rw.WriteHeader(rec.Code)
+
+	_, _ = rw.Write(rec.Body.Bytes()) // nolint:errcheck // Client may be gone.
+}
+
+// fail writes broken response to a hijacked connection and closes it.
+//
+// Dropped connection is reset, so that client receives RST instead of graceful close.
+func (b *behavior) fail(rw http.ResponseWriter, rec *httptest.ResponseRecorder) {
+	h, ok := rw.(http.Hijacker)
+	if !ok {
+		panic(http.ErrAbortHandler)
+	}
+
+	conn, buf, err := h.Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	defer conn.Close() // nolint:errcheck // Connection is broken on purpose.
+
+	if b.drop {
+		if tc, ok := conn.(*net.TCPConn); ok {
+			_ = tc.SetLinger(0) // nolint:errcheck // Graceful close is a fallback.
+		}
+
+		return
+	}
+
+	body := rec.Body.Bytes()
+	raw := bytes.NewBuffer(nil)
+
+	_, _ = fmt.Fprintf(raw, "HTTP/1.1 %d %s\r\n", rec.Code, http.StatusText(rec.Code))
+	_ = rec.Header().Write(raw) // nolint:errcheck // Buffer does not fail.
+
+	if b.malformedChunks {
+		// Chunk size is not a hexadecimal number.
+		_, _ = fmt.Fprintf(raw, "Transfer-Encoding: chunked\r\nConnection: close\r\n\r\nmalformed\r\n%s\r\n0\r\n\r\n", body)
+	} else {
+		contentLength := strconv.Itoa(len(body))
+
+		switch {
+		case b.invalidLength:
+			contentLength = "invalid"
+		case b.truncateAfter < len(body):
+			body = body[:b.truncateAfter]
+		case b.truncate:
+			// Short body is not cut, declared length still exceeds it.
+			contentLength = strconv.Itoa(b.truncateAfter + 1)
+		}
+
+		_, _ = fmt.Fprintf(raw, "Content-Length: %s\r\nConnection: close\r\n\r\n", contentLength)
+		_, _ = raw.Write(body)
+	}
+
+	_, _ = buf.Write(raw.Bytes())
+	_ = buf.Flush() // nolint:errcheck // Client may be gone.
+}
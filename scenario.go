@@ -0,0 +1,62 @@
+package httpdog
+
+import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+
+	"github.com/cucumber/godog"
+)
+
+// scenarioFile returns path of a file of a scenario without extension, relative to directory of files.
+//
+// Path is stable between runs and unique for each example of scenario outline:
+// directory is named after feature file, example is identified with hash of its values.
+func scenarioFile(sc *godog.Scenario) string {
+	feature := strings.TrimSuffix(filepath.Base(sc.Uri), filepath.Ext(sc.Uri))
+	name := fileName(sc.Name)
+
+	if h := exampleHash(sc); h != "" {
+		name += "-" + h
+	}
+
+	return filepath.Join(fileName(feature), name)
+}
+
+// exampleHash returns hash of values of example of scenario outline, empty string is returned for regular scenario.
+//
+// Values of example row are substituted in name and steps of scenario, so they are hashed instead of row
+// that is not available in godog. Hash does not change when other rows are added, removed or reordered.
+func exampleHash(sc *godog.Scenario) string {
+	// Example has AST nodes of outline and row.
+	if len(sc.AstNodeIds) < 2 {
+		return ""
+	}
+
+	h := fnv.New32a()
+
+	_, _ = h.Write([]byte(sc.Name))
+
+	for _, step := range sc.Steps {
+		_, _ = h.Write([]byte("\n" + step.Text))
+
+		if step.Argument == nil {
+			continue
+		}
+
+		if d := step.Argument.DocString; d != nil {
+			_, _ = h.Write([]byte("\n" + d.Content))
+		}
+
+		if t := step.Argument.DataTable; t != nil {
+			for _, row := range t.Rows {
+				for _, cell := range row.Cells {
+					_, _ = h.Write([]byte("\t" + cell.Value))
+				}
+			}
+		}
+	}
+
+	return fmt.Sprintf("%08x", h.Sum32())
+}
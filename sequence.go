@@ -0,0 +1,101 @@
+package httpdog
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// call is a request received by a mocked service.
+type call struct {
+	service    string
+	method     string
+	requestURI string
+	time       time.Time
+}
+
+// recordCall adds received request to timeline of the scenario.
+func (e *External) recordCall(service, method, requestURI string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.calls = append(e.calls, call{service: service, method: method, requestURI: requestURI, time: time.Now()})
+}
+
+// externalCallsHappenInOrder expects first calls of services in the order of a comma-separated list.
+func (e *External) externalCallsHappenInOrder(list string) error {
+	var services []string
+
+	for _, service := range strings.Split(list, ",") {
+		service = strings.Trim(strings.TrimSpace(service), `"`)
+
+		if _, ok := e.mocks[service]; !ok {
+			return fmt.Errorf("%w: %q", errNoMockForService, service)
+		}
+
+		services = append(services, service)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.orders = append(e.orders, services)
+
+	return nil
+}
+
+// checkOrders checks expected order of calls and describes timeline on failure.
+func (e *External) checkOrders() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var errs []string
+
+	// Position of first call of each service in timeline.
+	first := make(map[string]int)
+
+	for i, c := range e.calls {
+		if _, found := first[c.service]; !found {
+			first[c.service] = i
+		}
+	}
+
+	for _, services := range e.orders {
+		prev := -1
+
+		for _, service := range services {
+			i, found := first[service]
+
+			switch {
+			case !found:
+				errs = append(errs, fmt.Sprintf("%s was not called", service))
+			case i < prev:
+				errs = append(errs, fmt.Sprintf("%s was called before %s", service, e.calls[prev].service))
+			default:
+				prev = i
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s\n%s", errUnexpectedOrder, strings.Join(errs, ", "), e.timeline())
+}
+
+// timeline lists received calls with time offsets from the first one.
+func (e *External) timeline() string {
+	if len(e.calls) == 0 {
+		return "no calls received"
+	}
+
+	res := "call timeline:"
+
+	for i, c := range e.calls {
+		res += fmt.Sprintf("\n%d. +%s %s %s %s",
+			i+1, roundDuration(c.time.Sub(e.calls[0].time)), c.service, c.method, c.requestURI)
+	}
+
+	return res
+}
@@ -0,0 +1,64 @@
+package httpdog
+
+import (
+	"fmt"
+)
+
+func (e *External) serviceIsInState(service, state string) error {
+	if _, ok := e.mocks[service]; !ok {
+		return fmt.Errorf("%w: %q", errNoMockForService, service)
+	}
+
+	e.setState(service, state)
+
+	return nil
+}
+
+func (e *External) serviceRequestIsExpectedInState(service, state string) error {
+	if _, ok := e.mocks[service]; !ok {
+		return fmt.Errorf("%w: %q", errNoMockForService, service)
+	}
+
+	pending := e.pending[service]
+
+	if pending.Method == "" {
+		return fmt.Errorf("%w: %q", errUndefinedRequest, service)
+	}
+
+	pending.conditional = true
+	pending.state = state
+	e.pending[service] = pending
+
+	return nil
+}
+
+func (e *External) serviceResponseMovesToState(service, state string) error {
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.transition = true
+	b.state = state
+
+	return nil
+}
+
+func (e *External) setState(service, state string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.states == nil {
+		e.states = make(map[string]string, 1)
+	}
+
+	e.states[service] = state
+}
+
+// state returns current state of a service.
+func (e *External) state(service string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.states[service]
+}
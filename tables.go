@@ -0,0 +1,197 @@
+package httpdog
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/cucumber/godog"
+)
+
+// keyValue is a row of a two-column table.
+type keyValue struct {
+	key   string
+	value string
+}
+
+// keyValues reads two-column table with variables expanded in values.
+func (l *Local) keyValues(table *godog.Table) ([]keyValue, error) {
+	res := make([]keyValue, 0, len(table.Rows))
+
+	for _, row := range table.Rows {
+		if len(row.Cells) != 2 {
+			return nil, fmt.Errorf("%w: 2 cells expected, %d received", errInvalidTable, len(row.Cells))
+		}
+
+		value, err := generateVars(row.Cells[1].Value, l.JSONComparer.Vars)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, keyValue{key: row.Cells[0].Value, value: value})
+	}
+
+	return res, nil
+}
+
+// urlEncode encodes key-value pairs keeping order and repeated keys.
+func urlEncode(kvs []keyValue) string {
+	pairs := make([]string, 0, len(kvs))
+
+	for _, kv := range kvs {
+		pairs = append(pairs, url.QueryEscape(kv.key)+"="+url.QueryEscape(kv.value))
+	}
+
+	return strings.Join(pairs, "&")
+}
+
+func (l *Local) iRequestWithQueryParameters(table *godog.Table) error {
+	kvs, err := l.keyValues(table)
+	if err != nil {
+		return err
+	}
+
+	uri := l.req.uri
+
+	switch {
+	case strings.HasSuffix(uri, "?") || strings.HasSuffix(uri, "&"):
+		uri += urlEncode(kvs)
+	case strings.Contains(uri, "?"):
+		uri += "&" + urlEncode(kvs)
+	default:
+		uri += "?" + urlEncode(kvs)
+	}
+
+	l.req.uri = uri
+
+	return nil
+}
+
+func (l *Local) iRequestWithFormData(table *godog.Table) error {
+	kvs, err := l.keyValues(table)
+	if err != nil {
+		return err
+	}
+
+	body := []byte(urlEncode(kvs))
+
+	l.req.headers["Content-Type"] = "application/x-www-form-urlencoded"
+	l.req.body = body
+
+	return nil
+}
+
+func (l *Local) iRequestWithHeaders(table *godog.Table) error {
+	kvs, err := l.keyValues(table)
+	if err != nil {
+		return err
+	}
+
+	for _, kv := range kvs {
+		l.req.headers[http.CanonicalHeaderKey(kv.key)] = kv.value
+	}
+
+	return nil
+}
+
+// filePath returns path of file referred with @ prefix of value.
+//
+// Value with doubled @@ prefix is not a file, it is returned unescaped as a literal.
+func filePath(value string) (string, bool) {
+	switch {
+	case strings.HasPrefix(value, "@@"):
+		return value[1:], false
+	case strings.HasPrefix(value, "@"):
+		return value[1:], true
+	default:
+		return value, false
+	}
+}
+
+// quoteEscaper escapes field and file names in Content-Disposition header.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// iRequestWithMultipartFormData builds multipart body from table of fields.
+//
+// Value prefixed with @ is a path to file attachment, @@ prefix escapes literal @ in value.
+// Optional third cell defines content type of a part.
+func (l *Local) iRequestWithMultipartFormData(table *godog.Table) error {
+	buf := bytes.NewBuffer(nil)
+	w := multipart.NewWriter(buf)
+
+	for _, row := range table.Rows {
+		if len(row.Cells) != 2 && len(row.Cells) != 3 {
+			return fmt.Errorf("%w: 2 or 3 cells expected, %d received", errInvalidTable, len(row.Cells))
+		}
+
+		name := row.Cells[0].Value
+
+		// Prefix is checked before expansion, so that value of variable is never a file path.
+		value, isFile := filePath(row.Cells[1].Value)
+
+		value, err := generateVars(value, l.JSONComparer.Vars)
+		if err != nil {
+			return err
+		}
+
+		contentType := ""
+		if len(row.Cells) == 3 {
+			contentType = row.Cells[2].Value
+		}
+
+		h := make(textproto.MIMEHeader)
+		disposition := `form-data; name="` + quoteEscaper.Replace(name) + `"`
+		data := []byte(value)
+
+		if isFile {
+			data, err = ioutil.ReadFile(value) // nolint:gosec // File inclusion via variable during tests.
+			if err != nil {
+				return err
+			}
+
+			disposition += `; filename="` + quoteEscaper.Replace(filepath.Base(value)) + `"`
+
+			if contentType == "" {
+				contentType = mime.TypeByExtension(filepath.Ext(value))
+			}
+
+			if contentType == "" {
+				contentType = "application/octet-stream"
+			}
+		}
+
+		h.Set("Content-Disposition", disposition)
+
+		if contentType != "" {
+			h.Set("Content-Type", contentType)
+		}
+
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return err
+		}
+
+		if _, err := part.Write(data); err != nil {
+			return err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	body := buf.Bytes()
+	contentType := w.FormDataContentType()
+
+	l.req.headers["Content-Type"] = contentType
+	l.req.body = body
+
+	return nil
+}
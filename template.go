@@ -0,0 +1,108 @@
+package httpdog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"text/template"
+)
+
+// requestData is a received request available in response template.
+type requestData struct {
+	Method string
+	Path   string
+	Query  map[string]string
+	JSON   interface{}
+	Body   string
+
+	header http.Header
+}
+
+// Header returns value of request header.
+func (r requestData) Header(name string) string {
+	return r.header.Get(name)
+}
+
+func newRequestData(req *http.Request, body []byte) requestData {
+	r := requestData{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  make(map[string]string),
+		Body:   string(body),
+		header: req.Header,
+	}
+
+	for k, v := range req.URL.Query() {
+		r.Query[k] = v[0]
+	}
+
+	// Form data is available as JSON object of fields.
+	d := json.NewDecoder(bytes.NewReader(formToJSON(req.Header.Get("Content-Type"), body)))
+	d.UseNumber()
+
+	if err := d.Decode(&r.JSON); err != nil {
+		r.JSON = nil
+	}
+
+	return r
+}
+
+// templateFuncs are available in response template.
+var templateFuncs = template.FuncMap{
+	// json encodes value as JSON, strings are quoted and escaped.
+	"json": func(v interface{}) (string, error) {
+		j, err := json.Marshal(v)
+
+		return string(j), err
+	},
+}
+
+// parseTemplate prepares response body to be rendered for each received request.
+//
+// Missing key fails rendering instead of producing "<no value>".
+func parseTemplate(body []byte) (*template.Template, error) {
+	tpl, err := template.New("response").Option("missingkey=error").Funcs(templateFuncs).Parse(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid response template: %w", err)
+	}
+
+	return tpl, nil
+}
+
+// serviceRespondsWithStatusAndLoadedBody serves body with template actions as a template.
+//
+// Template is parsed once when expectation is defined and is rendered for each received request.
+func (e *External) serviceRespondsWithStatusAndLoadedBody(service, statusOrCode string, body []byte) error {
+	if !bytes.Contains(body, []byte("{{")) {
+		return e.serviceRespondsWithStatusAndPreparedBody(service, statusOrCode, body)
+	}
+
+	tpl, err := parseTemplate(body)
+	if err != nil {
+		return err
+	}
+
+	b, err := e.pendingBehavior(service)
+	if err != nil {
+		return err
+	}
+
+	b.template = tpl
+
+	return e.serviceRespondsWithStatusAndPreparedBody(service, statusOrCode, body)
+}
+
+// renderBody replaces recorded response body with template rendered for received request.
+func renderBody(rec *httptest.ResponseRecorder, tpl *template.Template, req *http.Request, reqBody []byte) error {
+	buf := bytes.NewBuffer(nil)
+
+	if err := tpl.Execute(buf, newRequestData(req, reqBody)); err != nil {
+		return fmt.Errorf("failed to render response template: %w", err)
+	}
+
+	rec.Body = buf
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package httpdog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+var errNoResponse = errors.New("response details are not available, make sure request was sent")
+
+// exchange is a round trip of Local client.
+type exchange struct {
+	req      *http.Request
+	reqBody  []byte
+	resp     *http.Response
+	respBody []byte
+	started  time.Time
+	duration time.Duration
+}
+
+// request is a configuration of Local request.
+type request struct {
+	method  string
+	uri     string
+	headers map[string]string
+	cookies map[string]string
+	body    []byte
+
+	// concurrency is a number of simultaneous requests to send.
+	concurrency int
+
+	// jitter spreads start of concurrent requests randomly within a duration.
+	jitter time.Duration
+}
+
+// send sends configured request unless response is already received.
+//
+// In concurrent mode responses are checked for idempotency, main response is one of a kind,
+// other responses are of another kind.
+func (l *Local) send() error {
+	if l.resp != nil {
+		return nil
+	}
+
+	n := l.req.concurrency
+	if n < 1 {
+		n = 1
+	}
+
+	var (
+		wg        sync.WaitGroup
+		exchanges = make([]exchange, n)
+		errs      = make([]error, n)
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if l.req.jitter > 0 {
+				time.Sleep(time.Duration(rand.Int63n(int64(l.req.jitter)))) //nolint:gosec // Weak random is enough for jitter.
+			}
+
+			exchanges[i], errs[i] = l.do()
+		}(i)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	l.exchanges = append(l.exchanges, exchanges...)
+
+	if l.HAR != nil {
+		for _, ex := range exchanges {
+			l.HAR.add(newHAREntry(ex.req, ex.reqBody, ex.resp.StatusCode, ex.resp.Header, ex.respBody,
+				ex.started, ex.duration, ""))
+		}
+	}
+
+	return l.split(exchanges)
+}
+
+// discard deletes received responses, so that configured request is sent again.
+func (l *Local) discard() {
+	l.exchanges = nil
+	l.validated = 0
+	l.resp = nil
+	l.other = nil
+	l.otherExpected = false
+}
+
+// do sends request once and returns exchange with decoded response body.
+//
+// Compression is negotiated by transport unless request has Accept-Encoding header.
+func (l *Local) do() (exchange, error) {
+	var body io.Reader
+
+	if len(l.req.body) > 0 {
+		body = bytes.NewReader(l.req.body)
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), l.req.method, l.baseURL+l.req.uri, body)
+	if err != nil {
+		return exchange{}, err
+	}
+
+	for k, v := range l.Headers {
+		req.Header.Set(k, v)
+	}
+
+	for k, v := range l.req.headers {
+		req.Header.Set(k, v)
+	}
+
+	cookies := make([]*http.Cookie, 0, len(l.Cookies)+len(l.req.cookies))
+
+	for n, v := range l.Cookies {
+		if _, found := l.req.cookies[n]; !found {
+			cookies = append(cookies, &http.Cookie{Name: n, Value: v})
+		}
+	}
+
+	for n, v := range l.req.cookies {
+		cookies = append(cookies, &http.Cookie{Name: n, Value: v})
+	}
+
+	sort.Slice(cookies, func(i, j int) bool {
+		return cookies[i].Name < cookies[j].Name
+	})
+
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+
+	ex := exchange{req: req, reqBody: l.req.body, started: time.Now()}
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		return exchange{}, err
+	}
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return exchange{}, err
+	}
+
+	if err = resp.Body.Close(); err != nil {
+		return exchange{}, err
+	}
+
+	ex.duration = time.Since(ex.started)
+	ex.resp = resp
+	ex.respBody = decoded(resp.Header.Get("Content-Encoding"), raw)
+
+	return ex, nil
+}
+
+// decoded returns uncompressed gzip body, other bodies are returned as is.
+func decoded(contentEncoding string, body []byte) []byte {
+	if contentEncoding != "gzip" {
+		return body
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+
+	res, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return body
+	}
+
+	return res
+}
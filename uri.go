@@ -0,0 +1,229 @@
+package httpdog
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bool64/shared"
+)
+
+var errInvalidPattern = errors.New("invalid URI pattern")
+
+// placeholder is a named path segment of URI template, e.g. {id} or {$user_id}.
+var placeholder = regexp.MustCompile(`\{([^{}/]+)\}`)
+
+// uriMatcher matches received request URI with expected one regardless of query parameters order.
+//
+// Path of request URI can be matched with a template or whole request URI can be matched with a regular expression.
+type uriMatcher struct {
+	uri     string
+	path    string
+	query   url.Values
+	partial bool
+
+	// pattern matches path (template) or request URI (regexp), names are variables to capture submatches.
+	pattern  *regexp.Regexp
+	names    []string
+	wholeURI bool
+}
+
+// uriPattern describes how expected request URI should be matched.
+type uriPattern int
+
+const (
+	exactURI uriPattern = iota
+	templateURI
+	regexpURI
+)
+
+func newURIMatcher(uri string, kind uriPattern, partial bool, vars *shared.Vars) (uriMatcher, error) {
+	m := uriMatcher{uri: uri, partial: partial}
+
+	if kind == regexpURI {
+		re, err := regexp.Compile(`^(?:` + uri + `)$`)
+		if err != nil {
+			return m, fmt.Errorf("%w: %v", errInvalidPattern, err)
+		}
+
+		m.pattern = re
+		m.wholeURI = true
+
+		for _, name := range re.SubexpNames()[1:] {
+			if name != "" {
+				name = varPrefix(vars) + name
+			}
+
+			m.names = append(m.names, name)
+		}
+
+		return m, nil
+	}
+
+	path, rawQuery := splitURI(uri)
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return m, err
+	}
+
+	m.path = path
+	m.query = query
+
+	if kind == templateURI {
+		m.pattern, m.names = templateRegexp(path, vars)
+	}
+
+	return m, nil
+}
+
+// templateRegexp converts path template to regular expression,
+// placeholders that are variable names are captured.
+func templateRegexp(path string, vars *shared.Vars) (*regexp.Regexp, []string) {
+	var (
+		expr  = "^"
+		names []string
+		pos   int
+	)
+
+	for _, loc := range placeholder.FindAllStringSubmatchIndex(path, -1) {
+		expr += regexp.QuoteMeta(path[pos:loc[0]]) + `([^/]+)`
+		name := path[loc[2]:loc[3]]
+
+		if vars == nil || !vars.IsVar(name) {
+			name = ""
+		}
+
+		names = append(names, name)
+		pos = loc[1]
+	}
+
+	expr += regexp.QuoteMeta(path[pos:]) + "$"
+
+	return regexp.MustCompile(expr), names
+}
+
+func varPrefix(vars *shared.Vars) string {
+	if vars == nil || vars.VarPrefix == "" {
+		return "$"
+	}
+
+	return vars.VarPrefix
+}
+
+// splitURI splits request URI into path and raw query.
+func splitURI(uri string) (string, string) {
+	if i := strings.Index(uri, "?"); i >= 0 {
+		return uri[:i], uri[i+1:]
+	}
+
+	return uri, ""
+}
+
+// match checks if received request URI is expected and returns values of captured variables.
+//
+// Partial matcher allows additional query parameters.
+// Variables that are already defined must have same values.
+func (m uriMatcher) match(requestURI string, vars *shared.Vars) (map[string]string, bool) {
+	if m.wholeURI {
+		return m.capture(requestURI, vars)
+	}
+
+	path, rawQuery := splitURI(requestURI)
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil || !m.matchQuery(query) {
+		return nil, false
+	}
+
+	if m.pattern == nil {
+		return nil, path == m.path
+	}
+
+	return m.capture(path, vars)
+}
+
+func (m uriMatcher) capture(s string, vars *shared.Vars) (map[string]string, bool) {
+	sub := m.pattern.FindStringSubmatch(s)
+	if sub == nil {
+		return nil, false
+	}
+
+	captured := make(map[string]string)
+
+	for i, name := range m.names {
+		if name == "" {
+			continue
+		}
+
+		value, err := url.PathUnescape(sub[i+1])
+		if err != nil {
+			value = sub[i+1]
+		}
+
+		if vars != nil {
+			if v, found := vars.Get(name); found {
+				if s, err := varString(v); err != nil || s != value {
+					return nil, false
+				}
+			}
+		}
+
+		captured[name] = value
+	}
+
+	return captured, true
+}
+
+func (m uriMatcher) matchQuery(query url.Values) bool {
+	if !m.partial && len(query) != len(m.query) {
+		return false
+	}
+
+	for k, expected := range m.query {
+		received, found := query[k]
+		if !found {
+			return false
+		}
+
+		if m.partial {
+			if !containsAll(received, expected) {
+				return false
+			}
+		} else if !sameValues(received, expected) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// sameValues checks if slices have same values in any order.
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	return containsAll(a, b)
+}
+
+// containsAll checks if all values (with repetitions) are in a set.
+func containsAll(set, values []string) bool {
+	counts := make(map[string]int, len(set))
+
+	for _, v := range set {
+		counts[v]++
+	}
+
+	for _, v := range values {
+		if counts[v] == 0 {
+			return false
+		}
+
+		counts[v]--
+	}
+
+	return true
+}